@@ -1,3 +1,5 @@
+//go:build ignore
+
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
@@ -1,3 +1,5 @@
+//go:build ignore
+
 //3阶导数的英文是 third derivative。
 
 //在更正式或书面的情况下，你可能会看到：
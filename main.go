@@ -3,9 +3,16 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"math"
+	"math/rand/v2"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,22 +20,44 @@ import (
 
 // ========== Constants ==========
 const (
-	mapWidth         = 20
-	mapHeight        = 15
 	maxPlayers       = 8
 	maxCities        = 50
 	maxUnits         = 100
-	startYear        = 4000
-	endYear          = 2050
 	minCityDistance  = 25
 	maxProductionQueue = 5
+	saveFileVersion    = 4
+	defaultSaveFile    = "civ_save.json"
+	emergencySaveFile  = "civ_emergency.json"
 	
 	// Game balance constants
-	researchSuccessChance = 30
-	combatSuccessChance   = 70
+	researchPerCity       = 1
+	researchPerPopulation = 2
+	combatBaseDamage      = 10
+	combatDamageRange     = 11
+	cityDefenseBonus      = 50
+	fortifyDefenseBonus   = 50
 	baseCityPopulation   = 1
+	cityRadius            = 2
+	foodPerCitizen        = 2
+	cityCenterFoodBonus   = 1
+	foodBoxBase           = 20
+	foodBoxPerCitizen     = 10
 	startingGold          = 100
+	taxPerCitizen         = 1
+	unitUpkeep            = 1
+	freeUnitsPerCity      = 2
+	buildingSellPercent   = 50
 	startingHappiness     = 100
+	contentCitizens       = 4
+	citiesBeforeUnhappiness = 4
+	warWearinessPerUnhappy  = 4
+	unhappyProductionPenalty = 10
+	riotTurns             = 3
+	revoltTurns           = 6
+	revoltRadius          = 6
+	ceasefireTurns        = 10
+	borderContactRadius   = 2
+	giftGold              = 50
 )
 
 // ========== Type Definitions ==========
@@ -39,6 +68,15 @@ type (
 	unitType          int
 	civilizationType  int
 	productionItemType int
+	mapGenerator      int
+	victoryType       int
+	unitOrder         int
+	promotionType     int
+	improvementType   int
+	routeType         int
+	workerJob         int
+	resourceType      int
+	projectType       int
 )
 
 // Terrain types
@@ -58,6 +96,11 @@ func (t terrainType) isValid() bool {
 	return t >= 0 && t < terrainCount
 }
 
+// isPassable reports whether land units can enter the terrain.
+func (t terrainType) isPassable() bool {
+	return t != terrainOcean && t != terrainMountains
+}
+
 // Building types
 const (
 	buildingMonument buildingType = iota
@@ -104,6 +147,7 @@ const (
 	unitMusketeer
 	unitCannon
 	unitTank
+	unitWorker
 	unitCount
 )
 
@@ -111,6 +155,12 @@ func (u unitType) isValid() bool {
 	return u >= 0 && u < unitCount
 }
 
+// isCivilian reports whether units of the type stay out of fights: they
+// can't attack and are never promoted.
+func (u unitType) isCivilian() bool {
+	return u == unitSettler || u == unitWorker
+}
+
 // Civilization types
 const (
 	civEgypt civilizationType = iota
@@ -128,10 +178,115 @@ func (c civilizationType) isValid() bool {
 	return c >= 0 && c < civCount
 }
 
+// Unit orders. The zero value is no order: the unit is idle and waits for
+// its owner.
+const (
+	orderNone unitOrder = iota
+	orderFortify
+	orderSentry
+	orderSkip
+	orderGoTo
+	orderExplore
+	orderAutomate
+	orderBuild
+	orderCount
+)
+
+// Tile improvements. A tile has at most one improvement and one route.
+const (
+	improvementNone improvementType = iota
+	improvementFarm
+	improvementMine
+	improvementCount
+)
+
+const (
+	routeNone routeType = iota
+	routeRoad
+	routeRailroad
+	routeCount
+)
+
+// Worker jobs
+const (
+	jobNone workerJob = iota
+	jobFarm
+	jobMine
+	jobRoad
+	jobRailroad
+	jobCount
+)
+
+// Resources. Wheat and Fish are food, Gold is wealth and a luxury, and
+// Iron and Horses are strategic: some units can't be built without them.
+const (
+	resourceNone resourceType = iota
+	resourceWheat
+	resourceFish
+	resourceGold
+	resourceIron
+	resourceHorses
+	resourceCount
+)
+
+// Promotions
+const (
+	promotionCombat promotionType = iota
+	promotionRanger
+	promotionMobility
+	promotionRally
+	promotionCount
+)
+
+// Diplomatic stances. The zero value is peace, which every pair of
+// civilizations starts in.
+type diplomaticStance int
+
+const (
+	stancePeace diplomaticStance = iota
+	stanceCeasefire
+	stanceWar
+	stanceCount
+)
+
+type proposalType int
+
+const (
+	proposeCeasefire proposalType = iota
+	proposePeace
+)
+
+// Map generators
+const (
+	mapGenContinents mapGenerator = iota
+	mapGenRandom
+	mapGenCount
+)
+
+// Victory conditions
+const (
+	victoryTime victoryType = iota
+	victoryConquest
+	victoryScience
+	victoryCulture
+	victoryDiplomatic
+	victoryCount
+)
+
 // Production item types
 const (
 	productionUnit productionItemType = iota
 	productionBuilding
+	productionProject
+)
+
+// Space program projects, built in order. Completing the last launches a
+// spaceship and wins a science victory.
+const (
+	projectApolloProgram projectType = iota
+	projectSpaceshipHull
+	projectSpaceshipEngines
+	projectCount
 )
 
 // ========== Game Structures ==========
@@ -143,10 +298,20 @@ type city struct {
 	Food          int
 	Buildings     []buildingType
 	ProductionQueue []productionItem
+	Overflow      int // shields left over from the last completed item
+	Culture       int
+	Unhappy       int
+	UnrestTurns   int
+	Captured      bool // taken this turn; the conqueror may still raze it
+	WorkedTiles   []tilePos
 	OwnerID       int
 	X, Y          int
 }
 
+type tilePos struct {
+	X, Y int
+}
+
 type productionItem struct {
 	Type      productionItemType
 	ItemID    int
@@ -157,8 +322,9 @@ type productionItem struct {
 
 type tile struct {
 	Terrain  terrainType
-	Resource string
-	Improved bool
+	Resource resourceType
+	Improvement improvementType
+	Route    routeType
 	CityID   int
 	UnitID   int
 	OwnerID  int
@@ -171,8 +337,13 @@ type unit struct {
 	Movement   int
 	Strength   int
 	Experience int
+	Promotions []promotionType
 	OwnerID    int
 	X, Y       int
+	Order      unitOrder
+	Destination *tilePos // where a go-to, explore or automated unit is heading
+	Job        workerJob // what a worker is building on its tile
+	JobProgress int      // turns of work put into Job so far
 }
 
 type player struct {
@@ -183,13 +354,29 @@ type player struct {
 	Units       map[int]*unit
 	Techs       map[techType]bool
 	Researching techType
+	ResearchProgress int
 	Gold        int
-	Happiness   int
+	Bankrupt    bool
+	Happiness   int // percentage of citizens who are content
+	WarWeariness int
 	IsAI        bool
-	Relations   map[int]int
+	Eliminated  bool // lost every city and settler; takes no more turns
+	Projects    [projectCount]bool // space program stages completed
+	Relations   map[int]int // how this player regards each other player
+	Treaties    map[int]treaty
 	Score       int
 	CityCount   int
 	UnitCount   int
+	Memory      [][]tileMemory // what this player last saw of each tile
+	
+	// visible marks the tiles this player's cities and units see now. It
+	// is rebuilt from their positions rather than saved.
+	visible [][]bool
+}
+
+type treaty struct {
+	Stance    diplomaticStance
+	TurnsLeft int // for a ceasefire, turns until it lapses back into war
 }
 
 type game struct {
@@ -198,10 +385,27 @@ type game struct {
 	Players            []*player
 	CurrentPlayerIndex int
 	WinnerID           int
+	Victory            victoryType // how WinnerID won
 	Running            bool
 	NextCityID         int
 	NextUnitID         int
 	TurnCount          int
+	Seed               uint64
+	Settings           gameSettings
+	
+	// The world vote: ballots cast this turn, voter to candidate, and the
+	// votes each civilization won when the last one was counted.
+	Ballots   map[int]int
+	VoteTally []int
+	VoteTurn  int
+	
+	// rng is the game's only source of randomness; rngSource is kept so
+	// its state can be written to and restored from save files.
+	rng       *rand.Rand
+	rngSource *rand.PCG
+	
+	// events collects what happened since the frontend last drained it.
+	events []gameEvent
 }
 
 // ========== String Conversions ==========
@@ -209,10 +413,44 @@ var (
 	terrainNames = [terrainCount]string{"Ocean", "Plains", "Desert", "Mountains", "Forest", "Hills", "Tundra", "Jungle"}
 	buildingNames = [buildingCount]string{"Monument", "Granary", "Library", "Temple", "Barracks", "Walls", "University", "Factory"}
 	techNames = [techCount]string{"Agriculture", "Pottery", "Writing", "Mathematics", "Construction", "Philosophy", "Engineering", "Education", "Gunpowder", "Industrialization"}
-	unitNames = [unitCount]string{"Settler", "Warrior", "Archer", "Swordsman", "Knight", "Musketeer", "Cannon", "Tank"}
+	unitNames = [unitCount]string{"Settler", "Warrior", "Archer", "Swordsman", "Knight", "Musketeer", "Cannon", "Tank", "Worker"}
 	civNames = [civCount]string{"Egypt", "Greece", "Rome", "China", "Persia", "Inca", "England", "France"}
+	stanceNames = [stanceCount]string{"Peace", "Ceasefire", "War"}
+	mapGeneratorNames = [mapGenCount]string{"continents", "random"}
+	victoryNames = [victoryCount]string{"Time", "Conquest", "Science", "Culture", "Diplomatic"}
+	promotionNames = [promotionCount]string{"Combat", "Ranger", "Mobility", "Rally"}
+	orderNames = [orderCount]string{"None", "Fortified", "Sentry", "Skipping Turn", "Go To", "Exploring", "Automated", "Building"}
+	jobNames = [jobCount]string{"None", "Farm", "Mine", "Road", "Railroad"}
+	resourceNames = [resourceCount]string{"None", "Wheat", "Fish", "Gold", "Iron", "Horses"}
+	projectNames = [projectCount]string{"Apollo Program", "Spaceship Hull", "Spaceship Engines"}
+)
+
+// Movement points are counted in sixths of a move so that roads and
+// railroads can cost a fraction of one.
+const (
+	movementScale    = 6
+	roadMoveCost     = 2 // a third of a move
+	railroadMoveCost = 1 // a sixth of a move
 )
 
+// terrainMoveCost is the moves it takes to step onto the terrain, before
+// movementScale. Impassable terrain is left at zero.
+var terrainMoveCost = [terrainCount]int{
+	terrainPlains: 1,
+	terrainDesert: 1,
+	terrainForest: 2,
+	terrainHills:  2,
+	terrainTundra: 1,
+	terrainJungle: 2,
+}
+
+// terrainDefenseBonus is the percentage added to a defender's strength.
+var terrainDefenseBonus = [terrainCount]int{
+	terrainForest: 25,
+	terrainHills:  50,
+	terrainJungle: 25,
+}
+
 func terrainToString(t terrainType) string {
 	if t.isValid() {
 		return terrainNames[t]
@@ -241,6 +479,95 @@ func unitToString(u unitType) string {
 	return "Unknown"
 }
 
+func promotionToString(p promotionType) string {
+	if p >= 0 && p < promotionCount {
+		return promotionNames[p]
+	}
+	return "Unknown"
+}
+
+func projectToString(p projectType) string {
+	if p >= 0 && p < projectCount {
+		return projectNames[p]
+	}
+	return "Unknown"
+}
+
+func resourceToString(r resourceType) string {
+	if r >= 0 && r < resourceCount {
+		return resourceNames[r]
+	}
+	return "Unknown"
+}
+
+// Resources are saved by name, as they were when they were plain strings,
+// so older save files still load.
+func (r resourceType) MarshalText() ([]byte, error) {
+	if r == resourceNone {
+		return []byte{}, nil
+	}
+	return []byte(resourceToString(r)), nil
+}
+
+func (r *resourceType) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*r = resourceNone
+		return nil
+	}
+	for res := resourceNone + 1; res < resourceCount; res++ {
+		if resourceNames[res] == string(text) {
+			*r = res
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown resource %q", text)
+}
+
+func jobToString(j workerJob) string {
+	if j >= 0 && j < jobCount {
+		return jobNames[j]
+	}
+	return "Unknown"
+}
+
+func orderToString(o unitOrder) string {
+	if o >= 0 && o < orderCount {
+		return orderNames[o]
+	}
+	return "Unknown"
+}
+
+func stanceToString(s diplomaticStance) string {
+	if s >= 0 && s < stanceCount {
+		return stanceNames[s]
+	}
+	return "Unknown"
+}
+
+func parseMapGenerator(name string) (mapGenerator, error) {
+	for i, n := range mapGeneratorNames {
+		if strings.EqualFold(name, n) {
+			return mapGenerator(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown map generator %q", errInvalidInput, name)
+}
+
+func victoryToString(v victoryType) string {
+	if v < 0 || v >= victoryCount {
+		return "Unknown"
+	}
+	return victoryNames[v]
+}
+
+// formatYear renders a year as BC or AD; negative years are BC.
+func formatYear(year int) string {
+	if year < 0 {
+		return fmt.Sprintf("%d BC", -year)
+	}
+	return fmt.Sprintf("%d AD", year)
+}
+
 func civToString(c civilizationType) string {
 	if c.isValid() {
 		return civNames[c]
@@ -248,6 +575,29 @@ func civToString(c civilizationType) string {
 	return "Unknown"
 }
 
+// ========== Player Helpers ==========
+// sortedCities returns the player's cities ordered by ID. Anything that
+// draws from the game RNG or presents a numbered list must iterate in a
+// stable order, since map iteration order is randomized.
+func (p *player) sortedCities() []*city {
+	cities := make([]*city, 0, len(p.Cities))
+	for _, c := range p.Cities {
+		cities = append(cities, c)
+	}
+	sort.Slice(cities, func(i, j int) bool { return cities[i].ID < cities[j].ID })
+	return cities
+}
+
+// sortedUnits returns the player's units ordered by ID.
+func (p *player) sortedUnits() []*unit {
+	units := make([]*unit, 0, len(p.Units))
+	for _, u := range p.Units {
+		units = append(units, u)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	return units
+}
+
 // ========== Error Handling ==========
 type gameError struct {
 	Code    string
@@ -260,6 +610,7 @@ func (e gameError) Error() string {
 
 var (
 	errInvalidInput     = gameError{Code: "INVALID_INPUT", Message: "invalid input provided"}
+	errInputClosed      = gameError{Code: "INPUT_CLOSED", Message: "no more input"}
 	errOutOfBounds      = gameError{Code: "OUT_OF_BOUNDS", Message: "index out of bounds"}
 	errInvalidTerrain   = gameError{Code: "INVALID_TERRAIN", Message: "invalid terrain type"}
 	errInvalidUnit      = gameError{Code: "INVALID_UNIT", Message: "invalid unit type"}
@@ -268,6 +619,45 @@ var (
 	errUnitNotFound     = gameError{Code: "UNIT_NOT_FOUND", Message: "unit not found"}
 	errInvalidMove      = gameError{Code: "INVALID_MOVE", Message: "cannot move to specified location"}
 	errProductionQueueFull = gameError{Code: "PRODUCTION_QUEUE_FULL", Message: "production queue is full"}
+	errSaveVersion      = gameError{Code: "SAVE_VERSION", Message: "unsupported save file version"}
+	errCorruptSave      = gameError{Code: "CORRUPT_SAVE", Message: "save file is corrupt"}
+	errGameLoaded       = gameError{Code: "GAME_LOADED", Message: "a saved game was loaded"}
+	errPlayerNotFound   = gameError{Code: "PLAYER_NOT_FOUND", Message: "player not found"}
+	errNotYourTurn      = gameError{Code: "NOT_YOUR_TURN", Message: "it is not this player's turn"}
+	errGameOver         = gameError{Code: "GAME_OVER", Message: "the game is over"}
+	errTileOccupied     = gameError{Code: "TILE_OCCUPIED", Message: "tile occupied by another unit"}
+	errNotSettler       = gameError{Code: "NOT_SETTLER", Message: "only settlers can found cities"}
+	errCityExists       = gameError{Code: "CITY_EXISTS", Message: "a city already exists on this tile"}
+	errCannotCapture    = gameError{Code: "CANNOT_CAPTURE", Message: "this unit cannot take cities"}
+	errCannotRaze       = gameError{Code: "CANNOT_RAZE", Message: "only a city captured this turn can be razed"}
+	errPlayerEliminated = gameError{Code: "PLAYER_ELIMINATED", Message: "that civilization has been eliminated"}
+	errForeignTerritory = gameError{Code: "FOREIGN_TERRITORY", Message: "tile is inside another civilization's borders"}
+	errInvalidName      = gameError{Code: "INVALID_NAME", Message: "name must be between 3 and 20 characters"}
+	errInvalidBuilding  = gameError{Code: "INVALID_BUILDING", Message: "invalid building type"}
+	errTechKnown        = gameError{Code: "TECH_KNOWN", Message: "technology already researched"}
+	errTechLocked       = gameError{Code: "TECH_LOCKED", Message: "technology prerequisites not met"}
+	errItemLocked       = gameError{Code: "ITEM_LOCKED", Message: "required technology not researched"}
+	errMissingResource  = gameError{Code: "MISSING_RESOURCE", Message: "required resource not in your territory"}
+	errBuildingExists   = gameError{Code: "BUILDING_EXISTS", Message: "city already has or is building this"}
+	errNoUnitPlacement  = gameError{Code: "NO_UNIT_PLACEMENT", Message: "no free tile to place the unit"}
+	errNothingToBuy     = gameError{Code: "NOTHING_TO_BUY", Message: "nothing in production to buy"}
+	errNotEnoughGold    = gameError{Code: "NOT_ENOUGH_GOLD", Message: "not enough gold"}
+	errNotAtWar         = gameError{Code: "NOT_AT_WAR", Message: "not at war with this civilization"}
+	errAlreadyAtWar     = gameError{Code: "ALREADY_AT_WAR", Message: "already at war with this civilization"}
+	errInvalidProposal  = gameError{Code: "INVALID_PROPOSAL", Message: "proposal does not apply to the current stance"}
+	errCannotAttack     = gameError{Code: "CANNOT_ATTACK", Message: "this unit cannot attack"}
+	errNotAdjacent      = gameError{Code: "NOT_ADJACENT", Message: "target is not adjacent"}
+	errNoMovesLeft      = gameError{Code: "NO_MOVES_LEFT", Message: "unit has no movement points left this turn"}
+	errOutOfRange       = gameError{Code: "OUT_OF_RANGE", Message: "destination is beyond the unit's remaining movement"}
+	errNoPath           = gameError{Code: "NO_PATH", Message: "no known route to the destination"}
+	errInvalidOrder     = gameError{Code: "INVALID_ORDER", Message: "unit cannot take that order"}
+	errNoPromotion      = gameError{Code: "NO_PROMOTION", Message: "unit has not earned a promotion"}
+	errPromotionKnown   = gameError{Code: "PROMOTION_KNOWN", Message: "unit already has that promotion"}
+	errNotWorker        = gameError{Code: "NOT_WORKER", Message: "only workers can build improvements"}
+	errCannotImprove    = gameError{Code: "CANNOT_IMPROVE", Message: "that improvement can't be built here"}
+	errInvalidSettings  = gameError{Code: "INVALID_SETTINGS", Message: "invalid game settings"}
+	errNoVote           = gameError{Code: "NO_VOTE", Message: "no world vote is being held"}
+	errVictoryDisabled  = gameError{Code: "VICTORY_DISABLED", Message: "that victory condition is not enabled in this game"}
 )
 
 // ========== Input Validation ==========
@@ -288,7 +678,7 @@ func (iv *inputValidator) getIntInput(prompt string, min, max int) (int, error)
 		if err := iv.scanner.Err(); err != nil {
 			return 0, fmt.Errorf("failed to read input: %w", err)
 		}
-		return 0, errInvalidInput
+		return 0, errInputClosed
 	}
 	
 	input := strings.TrimSpace(iv.scanner.Text())
@@ -311,7 +701,7 @@ func (iv *inputValidator) getStringInput(prompt string, minLen, maxLen int) (str
 		if err := iv.scanner.Err(); err != nil {
 			return "", fmt.Errorf("failed to read input: %w", err)
 		}
-		return "", errInvalidInput
+		return "", errInputClosed
 	}
 	
 	input := strings.TrimSpace(iv.scanner.Text())
@@ -336,48 +726,223 @@ func (iv *inputValidator) getChoiceInput(prompt string, options []string) (int,
 	return choice, nil
 }
 
+// ========== Game Settings ==========
+const (
+	minMapWidth     = 10
+	minMapHeight    = 10
+	maxMapWidth     = 80
+	maxMapHeight    = 50
+	minGameYear     = -10000 // earliest a game may start; BC years are negative
+	maxGameYear     = 3000   // latest a game may end
+	maxYearsPerTurn = 100
+)
+
+// gameSettings is everything chosen when a game is set up. It is kept on
+// the game, and saved with it, so a loaded game plays by the same rules.
+type gameSettings struct {
+	MapWidth     int
+	MapHeight    int
+	MapGenerator mapGenerator
+	Players      int // civilizations in the game
+	AIPlayers    int // how many of them the computer plays; humans come first
+	StartYear    int // negative years are BC
+	EndYear      int
+	YearsPerTurn int
+	Victories    [victoryCount]bool
+}
+
+func defaultSettings() gameSettings {
+	settings := gameSettings{
+		MapWidth:     20,
+		MapHeight:    15,
+		MapGenerator: mapGenContinents,
+		Players:      4,
+		AIPlayers:    3,
+		StartYear:    -4000,
+		EndYear:      2050,
+		YearsPerTurn: 10,
+	}
+	for v := range settings.Victories {
+		settings.Victories[v] = true
+	}
+	return settings
+}
+
+func (s gameSettings) validate() error {
+	switch {
+	case s.MapWidth < minMapWidth || s.MapWidth > maxMapWidth:
+		return fmt.Errorf("%w: map width must be between %d and %d", errInvalidSettings, minMapWidth, maxMapWidth)
+	case s.MapHeight < minMapHeight || s.MapHeight > maxMapHeight:
+		return fmt.Errorf("%w: map height must be between %d and %d", errInvalidSettings, minMapHeight, maxMapHeight)
+	case s.MapGenerator < 0 || s.MapGenerator >= mapGenCount:
+		return fmt.Errorf("%w: unknown map generator %d", errInvalidSettings, s.MapGenerator)
+	case s.Players < 2 || s.Players > maxPlayers:
+		return fmt.Errorf("%w: number of players must be between 2 and %d", errInvalidSettings, maxPlayers)
+	case s.AIPlayers < 0 || s.AIPlayers > s.Players:
+		return fmt.Errorf("%w: number of AI players must be between 0 and %d", errInvalidSettings, s.Players)
+	case s.YearsPerTurn < 1 || s.YearsPerTurn > maxYearsPerTurn:
+		return fmt.Errorf("%w: a turn must last between 1 and %d years", errInvalidSettings, maxYearsPerTurn)
+	case s.StartYear < minGameYear || s.StartYear >= maxGameYear:
+		return fmt.Errorf("%w: the game must start between %s and %s", errInvalidSettings, formatYear(minGameYear), formatYear(maxGameYear-1))
+	case s.EndYear <= s.StartYear || s.EndYear > maxGameYear:
+		return fmt.Errorf("%w: the game must end after %s and by %s", errInvalidSettings, formatYear(s.StartYear), formatYear(maxGameYear))
+	}
+	for _, enabled := range s.Victories {
+		if enabled {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: at least one victory condition must be enabled", errInvalidSettings)
+}
+
+// turns is how many turns the game lasts before the time limit.
+func (s gameSettings) turns() int {
+	return max((s.EndYear-s.StartYear)/s.YearsPerTurn, 1)
+}
+
+// settingsMenu walks the player through setting up a game, starting from
+// the given settings.
+func settingsMenu(settings gameSettings, validator *inputValidator) (gameSettings, error) {
+	sizes := []struct {
+		name          string
+		width, height int
+	}{
+		{"Skirmish", 10, 10},
+		{"Standard", 20, 15},
+		{"Large", 40, 25},
+		{"Campaign", 80, 50},
+	}
+	options := make([]string, 0, len(sizes)+1)
+	for _, size := range sizes {
+		options = append(options, fmt.Sprintf("%s (%dx%d)", size.name, size.width, size.height))
+	}
+	options = append(options, "Custom")
+	
+	generator, err := validator.getChoiceInput("\n🌍 Map Generator:", mapGeneratorNames[:])
+	if err != nil {
+		return settings, err
+	}
+	settings.MapGenerator = mapGenerator(generator - 1)
+	
+	choice, err := validator.getChoiceInput("\n🗺️ Map Size:", options)
+	if err != nil {
+		return settings, err
+	}
+	if choice <= len(sizes) {
+		settings.MapWidth, settings.MapHeight = sizes[choice-1].width, sizes[choice-1].height
+	} else {
+		if settings.MapWidth, err = validator.getIntInput(fmt.Sprintf("Map width (%d-%d): ", minMapWidth, maxMapWidth), minMapWidth, maxMapWidth); err != nil {
+			return settings, err
+		}
+		if settings.MapHeight, err = validator.getIntInput(fmt.Sprintf("Map height (%d-%d): ", minMapHeight, maxMapHeight), minMapHeight, maxMapHeight); err != nil {
+			return settings, err
+		}
+	}
+	
+	if settings.Players, err = validator.getIntInput(fmt.Sprintf("Enter number of players (2-%d): ", maxPlayers), 2, maxPlayers); err != nil {
+		return settings, err
+	}
+	if settings.AIPlayers, err = validator.getIntInput(fmt.Sprintf("How many are AI (0-%d): ", settings.Players), 0, settings.Players); err != nil {
+		return settings, err
+	}
+	
+	if settings.YearsPerTurn, err = validator.getIntInput(fmt.Sprintf("Years per turn (1-%d): ", maxYearsPerTurn), 1, maxYearsPerTurn); err != nil {
+		return settings, err
+	}
+	if settings.StartYear, err = validator.getIntInput(fmt.Sprintf("Start year (%d-%d, negative for BC): ", minGameYear, maxGameYear-1), minGameYear, maxGameYear-1); err != nil {
+		return settings, err
+	}
+	if settings.EndYear, err = validator.getIntInput(fmt.Sprintf("End year (%d-%d, negative for BC): ", settings.StartYear+1, maxGameYear), settings.StartYear+1, maxGameYear); err != nil {
+		return settings, err
+	}
+	
+	for v := range settings.Victories {
+		enabled, err := validator.getIntInput(fmt.Sprintf("Enable %s victory, %s? (1 = yes, 0 = no): ", victoryToString(victoryType(v)), victoryDescriptions[v]), 0, 1)
+		if err != nil {
+			return settings, err
+		}
+		settings.Victories[v] = enabled == 1
+	}
+	
+	return settings, settings.validate()
+}
+
+// wrap folds coordinates that stepped off an edge back onto the map.
+func (g *game) wrap(x, y int) (int, int) {
+	w, h := g.Settings.MapWidth, g.Settings.MapHeight
+	return ((x%w)+w)%w, ((y%h)+h)%h
+}
+
 // ========== Game Initialization ==========
-func newGame(numPlayers int) (*game, error) {
-	if numPlayers < 2 || numPlayers > maxPlayers {
-		return nil, fmt.Errorf("number of players must be between 2 and %d", maxPlayers)
+// newGame creates a game whose every random decision is drawn from a
+// generator seeded with seed, so the same seed and the same inputs always
+// replay the same game. A zero seed picks one from the clock.
+func newGame(settings gameSettings, seed uint64) (*game, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
 	}
 	
-	rand.Seed(time.Now().UnixNano())
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
 	
 	game := &game{
-		Year:       startYear,
+		Year:       settings.StartYear,
 		Running:    true,
 		WinnerID:  -1,
 		NextCityID: 1,
 		NextUnitID: 1,
 		TurnCount:  0,
+		Settings:   settings,
+		Ballots:    make(map[int]int),
 	}
+	game.seedRNG(seed)
 	
 	if err := game.generateMap(); err != nil {
 		return nil, fmt.Errorf("failed to generate map: %w", err)
 	}
 	
-	if err := game.createPlayers(numPlayers); err != nil {
+	if err := game.createPlayers(settings.Players); err != nil {
 		return nil, fmt.Errorf("failed to create players: %w", err)
 	}
+	game.updateTerritory()
+	for _, player := range game.Players {
+		game.updateVisibility(player)
+	}
 	
 	return game, nil
 }
 
+func (g *game) seedRNG(seed uint64) {
+	g.Seed = seed
+	g.rngSource = rand.NewPCG(seed, seed)
+	g.rng = rand.New(g.rngSource)
+}
+
 func (g *game) generateMap() error {
-	g.Map = make([][]tile, mapHeight)
-	for y := 0; y < mapHeight; y++ {
-		g.Map[y] = make([]tile, mapWidth)
-		for x := 0; x < mapWidth; x++ {
-			terrain := terrainType(rand.Intn(int(terrainCount)))
+	switch g.Settings.MapGenerator {
+	case mapGenContinents:
+		return g.generateContinentMap()
+	case mapGenRandom:
+		return g.generateRandomMap()
+	}
+	return fmt.Errorf("%w: unknown map generator %d", errInvalidSettings, g.Settings.MapGenerator)
+}
+
+// generateRandomMap picks every tile's terrain independently.
+func (g *game) generateRandomMap() error {
+	g.Map = make([][]tile, g.Settings.MapHeight)
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		g.Map[y] = make([]tile, g.Settings.MapWidth)
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			terrain := terrainType(g.rng.IntN(int(terrainCount)))
 			if !terrain.isValid() {
 				return errInvalidTerrain
 			}
 			
-			resource := ""
-			if rand.Intn(10) == 0 {
-				resources := []string{"Wheat", "Fish", "Gold", "Iron", "Horses"}
-				resource = resources[rand.Intn(len(resources))]
+			resource := resourceNone
+			if g.rng.IntN(10) == 0 {
+				resource = resourceType(1 + g.rng.IntN(int(resourceCount)-1))
 			}
 			
 			g.Map[y][x] = tile{
@@ -392,6 +957,185 @@ func (g *game) generateMap() error {
 	return nil
 }
 
+// Continent generator tuning
+const (
+	landFraction     = 0.40 // share of the map above sea level
+	mountainFraction = 0.10 // share of the land that is mountains
+	hillFraction     = 0.15 // share of the land that is hills
+)
+
+// generateContinentMap raises landmasses out of a wrapping elevation
+// noise field. The highest land becomes mountain ranges and hills, and the
+// rest is given a climate from its latitude and a second moisture field:
+// Tundra near the poles, Jungle and Desert toward the equator, Forest where
+// it is wet. Resources are then placed where their terrain suits them.
+func (g *game) generateContinentMap() error {
+	elevation := g.valueNoise([]int{6, 3, 2})
+	moisture := g.valueNoise([]int{5, 2})
+	
+	seaLevel := quantile(elevation, 1-landFraction)
+	mountainLevel := quantile(elevation, 1-landFraction*mountainFraction)
+	hillLevel := quantile(elevation, 1-landFraction*(mountainFraction+hillFraction))
+	
+	g.Map = make([][]tile, g.Settings.MapHeight)
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		g.Map[y] = make([]tile, g.Settings.MapWidth)
+		// 0 at the equator, 1 at the poles
+		latitude := math.Abs(float64(y)-float64(g.Settings.MapHeight-1)/2) / (float64(g.Settings.MapHeight-1) / 2)
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			e, m := elevation[y][x], moisture[y][x]
+			
+			var terrain terrainType
+			switch {
+			case e < seaLevel:
+				terrain = terrainOcean
+			case e >= mountainLevel:
+				terrain = terrainMountains
+			case latitude > 0.8:
+				terrain = terrainTundra
+			case e >= hillLevel:
+				terrain = terrainHills
+			case latitude < 0.35 && m > 0.6:
+				terrain = terrainJungle
+			case latitude < 0.6 && m < 0.35:
+				terrain = terrainDesert
+			case m > 0.55:
+				terrain = terrainForest
+			default:
+				terrain = terrainPlains
+			}
+			
+			g.Map[y][x] = tile{
+				Terrain: terrain,
+				CityID:  -1,
+				UnitID:  -1,
+				OwnerID: -1,
+			}
+		}
+	}
+	
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			g.Map[y][x].Resource = g.terrainResource(x, y)
+		}
+	}
+	return nil
+}
+
+// terrainResource rolls a resource suited to the tile: Fish off coasts,
+// Wheat and Horses on open land, Iron in hills and Gold in the mountains
+// and deserts.
+func (g *game) terrainResource(x, y int) resourceType {
+	roll := g.rng.IntN(100)
+	switch g.Map[y][x].Terrain {
+	case terrainOcean:
+		if g.passableNeighbors(x, y) > 0 && roll < 15 {
+			return resourceFish
+		}
+	case terrainPlains:
+		if roll < 10 {
+			return resourceWheat
+		}
+		if roll < 16 {
+			return resourceHorses
+		}
+	case terrainHills:
+		if roll < 20 {
+			return resourceIron
+		}
+	case terrainMountains, terrainDesert:
+		if roll < 12 {
+			return resourceGold
+		}
+	case terrainTundra:
+		if roll < 6 {
+			return resourceHorses
+		}
+	}
+	return resourceNone
+}
+
+// valueNoise builds a field in [0,1] by summing octaves of smoothly
+// interpolated random lattices. cells gives each octave's lattice spacing
+// in tiles; each octave has half the weight of the one before. The
+// lattices wrap like the map does.
+func (g *game) valueNoise(cells []int) [][]float64 {
+	field := make([][]float64, g.Settings.MapHeight)
+	for y := range field {
+		field[y] = make([]float64, g.Settings.MapWidth)
+	}
+	
+	weight, total := 1.0, 0.0
+	for _, cell := range cells {
+		latticeW, latticeH := max((g.Settings.MapWidth+cell-1)/cell, 1), max((g.Settings.MapHeight+cell-1)/cell, 1)
+		lattice := make([][]float64, latticeH)
+		for ly := range lattice {
+			lattice[ly] = make([]float64, latticeW)
+			for lx := range lattice[ly] {
+				lattice[ly][lx] = g.rng.Float64()
+			}
+		}
+		
+		for y := 0; y < g.Settings.MapHeight; y++ {
+			fy := float64(y) / float64(cell)
+			y0 := int(fy) % latticeH
+			y1 := (y0 + 1) % latticeH
+			ty := smoothstep(fy - math.Floor(fy))
+			for x := 0; x < g.Settings.MapWidth; x++ {
+				fx := float64(x) / float64(cell)
+				x0 := int(fx) % latticeW
+				x1 := (x0 + 1) % latticeW
+				tx := smoothstep(fx - math.Floor(fx))
+				
+				top := lerp(lattice[y0][x0], lattice[y0][x1], tx)
+				bottom := lerp(lattice[y1][x0], lattice[y1][x1], tx)
+				field[y][x] += weight * lerp(top, bottom, ty)
+			}
+		}
+		total += weight
+		weight /= 2
+	}
+	
+	for y := range field {
+		for x := range field[y] {
+			field[y][x] /= total
+		}
+	}
+	return field
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// quantile returns the value below which fraction q of the field lies.
+func quantile(field [][]float64, q float64) float64 {
+	var values []float64
+	for _, row := range field {
+		values = append(values, row...)
+	}
+	sort.Float64s(values)
+	i := min(max(int(q*float64(len(values))), 0), len(values)-1)
+	return values[i]
+}
+
+// passableNeighbors counts the land tiles units can enter next to (x, y).
+func (g *game) passableNeighbors(x, y int) int {
+	count := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && g.isValidTile(g.wrap(x+dx, y+dy)) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 func (g *game) createPlayers(numPlayers int) error {
 	for i := 0; i < numPlayers; i++ {
 		if i >= len(civNames) {
@@ -407,8 +1151,9 @@ func (g *game) createPlayers(numPlayers int) error {
 			Techs:       make(map[techType]bool),
 			Gold:        startingGold,
 			Happiness:   startingHappiness,
-			IsAI:        i > 0,
+			IsAI:        i >= numPlayers-g.Settings.AIPlayers,
 			Relations:   make(map[int]int),
+			Treaties:    make(map[int]treaty),
 			Score:       0,
 			CityCount:   0,
 			UnitCount:   0,
@@ -425,6 +1170,7 @@ func (g *game) createPlayers(numPlayers int) error {
 		for j := 0; j < numPlayers; j++ {
 			if j != i {
 				player.Relations[j] = 0
+				player.Treaties[j] = treaty{Stance: stancePeace}
 			}
 		}
 		
@@ -458,12 +1204,12 @@ func (g *game) createPlayers(numPlayers int) error {
 
 func (g *game) findStartingPosition(playerID, numPlayers int) (int, int, error) {
 	maxAttempts := 100
-	directions := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
 	
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		x, y := rand.Intn(mapWidth), rand.Intn(mapHeight)
+		x, y := g.rng.IntN(g.Settings.MapWidth), g.rng.IntN(g.Settings.MapHeight)
 		
-		if !g.isValidTile(x, y) {
+		// Don't start boxed in by water and mountains
+		if !g.canStartAt(x, y) || g.passableNeighbors(x, y) < 3 {
 			continue
 		}
 		
@@ -489,9 +1235,9 @@ func (g *game) findStartingPosition(playerID, numPlayers int) (int, int, error)
 	}
 	
 	// Fallback: find any valid position
-	for y := 0; y < mapHeight; y++ {
-		for x := 0; x < mapWidth; x++ {
-			if g.isValidTile(x, y) {
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			if g.canStartAt(x, y) {
 				return x, y, nil
 			}
 		}
@@ -500,10 +1246,24 @@ func (g *game) findStartingPosition(playerID, numPlayers int) (int, int, error)
 	return 0, 0, fmt.Errorf("could not find valid starting position")
 }
 
+// canStartAt reports whether a capital can be placed at (x, y) with room
+// for its starting warrior beside it.
+func (g *game) canStartAt(x, y int) bool {
+	if !g.isValidTile(x, y) || g.Map[y][x].CityID != -1 || g.Map[y][x].UnitID != -1 {
+		return false
+	}
+	for _, dir := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		nx, ny := g.wrap(x+dir[0], y+dir[1])
+		if g.isValidTile(nx, ny) && g.Map[ny][nx].UnitID == -1 {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *game) isValidTile(x, y int) bool {
-	return x >= 0 && x < mapWidth && y >= 0 && y < mapHeight &&
-		g.Map[y][x].Terrain != terrainOcean && 
-		g.Map[y][x].Terrain != terrainMountains
+	return x >= 0 && x < g.Settings.MapWidth && y >= 0 && y < g.Settings.MapHeight &&
+		g.Map[y][x].Terrain.isPassable()
 }
 
 func (g *game) createCapital(player *player, x, y int) (*city, error) {
@@ -535,9 +1295,9 @@ func (g *game) createStartingUnits(player *player, x, y int) (*unit, *unit, erro
 	settler := &unit{
 		ID:       g.NextUnitID,
 		Type:     unitSettler,
-		Health:   100,
-		Movement: 2,
-		Strength: 5,
+		Health:   maxHealth,
+		Movement: unitStats[unitSettler].Movement * movementScale,
+		Strength: unitStats[unitSettler].Strength,
 		OwnerID:  player.ID,
 		X:        x,
 		Y:        y,
@@ -548,9 +1308,9 @@ func (g *game) createStartingUnits(player *player, x, y int) (*unit, *unit, erro
 	warrior := &unit{
 		ID:       g.NextUnitID,
 		Type:     unitWarrior,
-		Health:   100,
-		Movement: 2,
-		Strength: 10,
+		Health:   maxHealth,
+		Movement: unitStats[unitWarrior].Movement * movementScale,
+		Strength: unitStats[unitWarrior].Strength,
 		OwnerID:  player.ID,
 	}
 	g.NextUnitID++
@@ -570,12 +1330,12 @@ func (g *game) findAdjacentTile(x, y int) (int, int, error) {
 	directions := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
 	// Shuffle directions for better distribution
 	for i := range directions {
-		j := rand.Intn(i + 1)
+		j := g.rng.IntN(i + 1)
 		directions[i], directions[j] = directions[j], directions[i]
 	}
 	
 	for _, dir := range directions {
-		newX, newY := (x+dir[0]+mapWidth)%mapWidth, (y+dir[1]+mapHeight)%mapHeight
+		newX, newY := g.wrap(x+dir[0], y+dir[1])
 		if g.isValidTile(newX, newY) && g.Map[newY][newX].UnitID == -1 {
 			return newX, newY, nil
 		}
@@ -584,85 +1344,354 @@ func (g *game) findAdjacentTile(x, y int) (int, int, error) {
 }
 
 // ========== AI Logic ==========
+const aiCitySpacing = 3 // closest the AI settles to a city it knows of
+
+// aiTurn drives an AI player through the engine API and the same unit
+// automation human players can hand their units to. It looks at the map
+// only through VisibleTile.
 func (g *game) aiTurn(player *player) error {
-	fmt.Printf("%s (AI) is thinking...\n", player.Name)
-	
-	// AI moves units
-	for _, unit := range player.Units {
-		if unit.Movement > 0 {
-			directions := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
-			dir := directions[rand.Intn(len(directions))]
-			newX, newY := (unit.X+dir[0]+mapWidth)%mapWidth, (unit.Y+dir[1]+mapHeight)%mapHeight
-			
-			if g.isValidTile(newX, newY) && g.Map[newY][newX].UnitID == -1 {
-				if err := g.moveUnit(unit, newX, newY); err == nil {
-					fmt.Printf("%s moved %s to (%d,%d)\n", player.Name, unitToString(unit.Type), newX, newY)
-				}
+	// AI diplomacy: declare war on those it hates, seek terms with AI
+	// rivals it has warmed to. Humans make their own proposals.
+	for _, other := range g.Players {
+		if other.ID == player.ID || other.Eliminated {
+			continue
+		}
+		relation := player.Relations[other.ID]
+		switch g.stance(player.ID, other.ID) {
+		case stancePeace:
+			if relation <= -60 {
+				g.DeclareWar(player.ID, other.ID)
+			}
+		case stanceWar:
+			if other.IsAI && relation+player.WarWeariness*5 >= -20 {
+				g.ProposeTreaty(player.ID, other.ID, proposeCeasefire)
+			}
+		case stanceCeasefire:
+			if other.IsAI && relation >= 0 {
+				g.ProposeTreaty(player.ID, other.ID, proposePeace)
 			}
 		}
 	}
 	
-	// AI manages cities
-	for _, city := range player.Cities {
+	// AI votes for its closest friend, if it has one worth backing
+	if g.voteOpen() {
+		g.CastVote(player.ID, g.aiVoteChoice(player))
+	}
+	
+	// AI units play themselves, as automated units do, once every city has
+	// its defender
+	garrisons := g.aiGarrison(player)
+	for _, unit := range player.sortedUnits() {
+		if _, alive := player.Units[unit.ID]; alive && !garrisons[unit.ID] {
+			g.automateUnit(player, unit)
+		}
+	}
+	
+	// AI manages cities, calming unhappy ones first
+	for _, city := range player.sortedCities() {
 		if len(city.ProductionQueue) == 0 {
-			if rand.Intn(2) == 0 {
-				unitType := unitType(rand.Intn(int(unitCount)))
-				if unitType.isValid() {
-					g.addToProductionQueue(city, productionUnit, int(unitType))
+			var buildings []buildingType
+			for _, b := range player.availableBuildings() {
+				if !city.hasBuilding(b) {
+					buildings = append(buildings, b)
 				}
-			} else {
-				buildingType := buildingType(rand.Intn(int(buildingCount)))
-				if buildingType.isValid() {
-					g.addToProductionQueue(city, productionBuilding, int(buildingType))
+			}
+			if city.Unhappy > 0 {
+				for _, b := range buildings {
+					if buildingEffects[b].Happiness > 0 {
+						buildings = []buildingType{b}
+						break
+					}
 				}
 			}
+			
+			var item productionItem
+			var err error = errItemLocked
+			// The space program comes first once it can be started
+			if project, ok := player.nextProject(); ok && g.Settings.Victories[victoryScience] {
+				item, err = g.EnqueueProduction(player.ID, city.ID, productionProject, int(project))
+			}
+			if err != nil {
+				if (g.rng.IntN(2) == 0 && city.Unhappy == 0) || len(buildings) == 0 {
+					units := g.availableUnits(player)
+					unitType := units[g.rng.IntN(len(units))]
+					item, err = g.EnqueueProduction(player.ID, city.ID, productionUnit, int(unitType))
+				} else {
+					buildingType := buildings[g.rng.IntN(len(buildings))]
+					item, err = g.EnqueueProduction(player.ID, city.ID, productionBuilding, int(buildingType))
+				}
+			}
+			if err == nil {
+				g.logEvent(player.ID, "%s started producing %s", city.Name, item.Name)
+			}
+		}
+	}
+	
+	// AI rushes production when it has gold to spare
+	for _, city := range player.sortedCities() {
+		if len(city.ProductionQueue) > 0 && player.Gold >= 2*buyPrice(city.ProductionQueue[0]) {
+			if price, err := g.BuyProduction(player.ID, city.ID); err == nil {
+				g.logEvent(player.ID, "%s bought %s for %d gold", city.Name, city.ProductionQueue[0].Name, price)
+			}
 		}
 	}
 	
 	// AI research
-	if rand.Intn(100) < 50 {
-		player.Researching = g.chooseNextTech(player)
-		fmt.Printf("%s started researching %s\n", player.Name, techToString(player.Researching))
+	if g.rng.IntN(100) < 50 {
+		if err := g.SetResearch(player.ID, g.chooseNextTech(player)); err == nil {
+			g.logEvent(player.ID, "%s started researching %s", player.Name, techToString(player.Researching))
+		}
 	}
 	
 	return nil
 }
 
-// ========== Map Display ==========
-func (g *game) displayMap(player *player) {
-	fmt.Println("\nWorld Map:")
-	terrainSymbols := [terrainCount]string{"~", ".", "d", "^", "*", "▲", "t", "j"}
+// ========== Visibility ==========
+const (
+	unitSightRadius = 1
+	citySightRadius = 2
+)
+
+// tileMemory is what a player saw of a tile the last time it was in sight.
+// Units are not remembered; they move on.
+type tileMemory struct {
+	Explored    bool
+	Terrain     terrainType
+	Resource    resourceType
+	Improvement improvementType
+	Route       routeType
+	CityID      int
+	CityOwnerID int
+	OwnerID     int // whose territory the tile was in
+}
+
+// tileView is a tile as one player knows it: live while in sight,
+// remembered once out of sight, and blank if never explored.
+type tileView struct {
+	X, Y        int
+	Explored    bool
+	Visible     bool
+	Terrain     terrainType
+	Resource    resourceType
+	Improvement improvementType
+	Route       routeType
+	CityID      int
+	CityOwnerID int
+	OwnerID     int
+	UnitID      int
+	UnitOwnerID int
+	UnitType    unitType // what kind of unit it is, as far as can be seen
+}
+
+// updateVisibility recomputes what the player sees from their cities and
+// units, and refreshes their memory of every tile in sight.
+func (g *game) updateVisibility(p *player) {
+	w, h := g.Settings.MapWidth, g.Settings.MapHeight
+	if len(p.Memory) != h || len(p.Memory[0]) != w {
+		p.Memory = make([][]tileMemory, h)
+		for y := range p.Memory {
+			p.Memory[y] = make([]tileMemory, w)
+		}
+	}
+	p.visible = make([][]bool, h)
+	for y := range p.visible {
+		p.visible[y] = make([]bool, w)
+	}
 	
-	for y := 0; y < mapHeight; y++ {
-		for x := 0; x < mapWidth; x++ {
-			tile := g.Map[y][x]
-			symbol := terrainSymbols[tile.Terrain]
+	reveal := func(cx, cy, radius int) {
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				x, y := g.wrap(cx+dx, cy+dy)
+				p.visible[y][x] = true
+			}
+		}
+	}
+	for _, c := range p.Cities {
+		reveal(c.X, c.Y, citySightRadius)
+	}
+	for _, u := range p.Units {
+		reveal(u.X, u.Y, unitSightRadius)
+	}
+	
+	for y := range p.visible {
+		for x, seen := range p.visible[y] {
+			if !seen {
+				continue
+			}
+			t := g.Map[y][x]
+			memory := tileMemory{
+				Explored:    true,
+				Terrain:     t.Terrain,
+				Resource:    t.Resource,
+				Improvement: t.Improvement,
+				Route:       t.Route,
+				CityID:      t.CityID,
+				CityOwnerID: -1,
+				OwnerID:     t.OwnerID,
+			}
+			if c, err := g.findCity(t.CityID); err == nil {
+				memory.CityOwnerID = c.OwnerID
+			}
+			p.Memory[y][x] = memory
+		}
+	}
+}
+
+func (g *game) isVisible(p *player, x, y int) bool {
+	return p.visible != nil && p.visible[y][x]
+}
+
+// VisibleTile returns what the current player knows of the tile at
+// (x, y). It is how the AI and other frontends should look at the map;
+// anything read straight from the game state is information the player
+// doesn't have.
+func (g *game) VisibleTile(playerID, x, y int) (tileView, error) {
+	player, err := g.Player(playerID)
+	if err != nil {
+		return tileView{}, err
+	}
+	return g.knownTile(player, x, y)
+}
+
+// knownTile is what the player knows of the tile at (x, y), whoever's
+// turn it is. The engine uses it to act for players between turns.
+func (g *game) knownTile(player *player, x, y int) (tileView, error) {
+	if x < 0 || x >= g.Settings.MapWidth || y < 0 || y >= g.Settings.MapHeight {
+		return tileView{}, errOutOfBounds
+	}
+	
+	view := tileView{X: x, Y: y, CityID: -1, CityOwnerID: -1, OwnerID: -1, UnitID: -1, UnitOwnerID: -1}
+	if player.Memory == nil || !player.Memory[y][x].Explored {
+		return view, nil
+	}
+	memory := player.Memory[y][x]
+	view.Explored = true
+	view.Terrain, view.Resource = memory.Terrain, memory.Resource
+	view.Improvement, view.Route = memory.Improvement, memory.Route
+	view.CityID, view.CityOwnerID = memory.CityID, memory.CityOwnerID
+	view.OwnerID = memory.OwnerID
+	
+	if g.isVisible(player, x, y) {
+		view.Visible = true
+		if u, err := g.findUnit(g.Map[y][x].UnitID); err == nil {
+			view.UnitID, view.UnitOwnerID, view.UnitType = u.ID, u.OwnerID, u.Type
+		}
+	}
+	return view, nil
+}
+
+// knownCities lists where the player has seen cities.
+func (g *game) knownCities(player *player) []tilePos {
+	var cities []tilePos
+	for y, row := range player.Memory {
+		for x, memory := range row {
+			if memory.Explored && memory.CityID != -1 {
+				cities = append(cities, tilePos{X: x, Y: y})
+			}
+		}
+	}
+	return cities
+}
+
+// aiGarrison keeps a defender fortified in each of the player's cities. A
+// military unit standing in a city digs in and stays there, and the
+// nearest free one is sent to a city left empty unless one is already on
+// its way. It returns the units it gave orders, which sit out the rest of
+// the turn.
+func (g *game) aiGarrison(p *player) map[int]bool {
+	busy := make(map[int]bool)
+	var empty []*city
+	for _, c := range p.sortedCities() {
+		u, err := g.findUnit(g.Map[c.Y][c.X].UnitID)
+		if err != nil || u.Type.isCivilian() {
+			empty = append(empty, c)
+			continue
+		}
+		busy[u.ID] = true
+		if u.Order != orderFortify {
+			g.SetOrder(p.ID, u.ID, orderFortify)
+		}
+	}
+	
+	for _, c := range empty {
+		pos := tilePos{X: c.X, Y: c.Y}
+		var nearest *unit
+		onTheWay := false
+		for _, u := range p.sortedUnits() {
+			if busy[u.ID] || u.Type.isCivilian() {
+				continue
+			}
+			if u.Destination != nil && *u.Destination == pos {
+				onTheWay = true
+				break
+			}
+			if nearest == nil || g.distance(u.X, u.Y, c.X, c.Y) < g.distance(nearest.X, nearest.Y, c.X, c.Y) {
+				nearest = u
+			}
+		}
+		if onTheWay || nearest == nil {
+			continue
+		}
+		if _, err := g.GoTo(p.ID, nearest.ID, c.X, c.Y); err == nil {
+			busy[nearest.ID] = true
+		}
+	}
+	return busy
+}
+
+// aiGoodCitySite reports whether the player knows (x, y) to be open land
+// far enough from the known cities.
+func (g *game) aiGoodCitySite(player *player, x, y int, cities []tilePos) bool {
+	view, err := g.knownTile(player, x, y)
+	if err != nil || !view.Explored || !view.Terrain.isPassable() || view.CityID != -1 {
+		return false
+	}
+	if view.OwnerID != -1 && view.OwnerID != player.ID {
+		return false
+	}
+	for _, c := range cities {
+		if g.distance(x, y, c.X, c.Y) <= aiCitySpacing {
+			return false
+		}
+	}
+	return true
+}
+
+// citySites lists the tiles the player knows to be good city sites.
+func (g *game) citySites(player *player) []tilePos {
+	var sites []tilePos
+	cities := g.knownCities(player)
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			if g.aiGoodCitySite(player, x, y, cities) {
+				sites = append(sites, tilePos{X: x, Y: y})
+			}
+		}
+	}
+	return sites
+}
+
+// ========== Map Display ==========
+func (g *game) displayMap(player *player) {
+	fmt.Println("\nWorld Map:")
+	terrainSymbols := [terrainCount]string{"~", ".", "d", "^", "*", "▲", "t", "j"}
+	
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			view, err := g.knownTile(player, x, y)
+			if err != nil || !view.Explored {
+				fmt.Print("   ")
+				continue
+			}
+			symbol := terrainSymbols[view.Terrain]
 			
-			if tile.CityID != -1 {
-				for _, p := range g.Players {
-					if city, exists := p.Cities[tile.CityID]; exists {
-						if p.ID == player.ID {
-							symbol = "C"
-						} else {
-							symbol = string(p.Name[0])
-						}
-						break
-					}
-				}
-			} else if tile.UnitID != -1 {
-				for _, p := range g.Players {
-					if unit, exists := p.Units[tile.UnitID]; exists {
-						if p.ID == player.ID {
-							symbol = "U"
-						} else {
-							symbol = string(p.Name[0])
-						}
-						break
-					}
-				}
+			if view.CityID != -1 {
+				symbol = g.ownerSymbol(player, view.CityOwnerID, "C")
+			} else if view.UnitID != -1 {
+				symbol = g.ownerSymbol(player, view.UnitOwnerID, "U")
 			}
 			
-			fmt.Printf("%s ", symbol)
+			fmt.Printf("%s%s%s", g.borderSymbol(player, view), symbol, improvementSymbol(view))
 		}
 		fmt.Println()
 	}
@@ -673,6 +1702,44 @@ func (g *game) displayMap(player *player) {
 	fmt.Println(". - Plains, ~ - Ocean, ^ - Mountains")
 	fmt.Println("* - Forest, ▲ - Hills, d - Desert")
 	fmt.Println("t - Tundra, j - Jungle")
+	fmt.Println("After a tile: f - Farm, m - Mine, = - Road, # - Railroad")
+	fmt.Println("Before a tile: : - Your territory, lowercase letter - another civilization's")
+	fmt.Println("Blank - Unexplored; cities out of sight are shown as last seen")
+}
+
+// borderSymbol marks whose territory a tile is in.
+func (g *game) borderSymbol(viewer *player, view tileView) string {
+	if view.OwnerID == -1 {
+		return " "
+	}
+	return strings.ToLower(g.ownerSymbol(viewer, view.OwnerID, ":"))
+}
+
+// improvementSymbol marks a tile's improvement, or failing that its route.
+func improvementSymbol(view tileView) string {
+	switch {
+	case view.Improvement == improvementFarm:
+		return "f"
+	case view.Improvement == improvementMine:
+		return "m"
+	case view.Route == routeRailroad:
+		return "#"
+	case view.Route == routeRoad:
+		return "="
+	}
+	return " "
+}
+
+// ownerSymbol marks something the viewer owns with own, and anything
+// else with its owner's initial.
+func (g *game) ownerSymbol(viewer *player, ownerID int, own string) string {
+	if ownerID == viewer.ID {
+		return own
+	}
+	if owner, err := g.findPlayer(ownerID); err == nil {
+		return string(owner.Name[0])
+	}
+	return "?"
 }
 
 // ========== Unit Movement ==========
@@ -683,9 +1750,9 @@ func (g *game) moveUnits(player *player, validator *inputValidator) error {
 	
 	unitList := make([]string, 0, player.UnitCount)
 	unitIDs := make([]int, 0, player.UnitCount)
-	for id, unit := range player.Units {
-		unitList = append(unitList, fmt.Sprintf("%s at (%d,%d)", unitToString(unit.Type), unit.X, unit.Y))
-		unitIDs = append(unitIDs, id)
+	for _, unit := range player.sortedUnits() {
+		unitList = append(unitList, unitSummary(unit))
+		unitIDs = append(unitIDs, unit.ID)
 	}
 	
 	choice, err := validator.getChoiceInput("\n🚶 Select Unit to Move:", unitList)
@@ -693,72 +1760,2850 @@ func (g *game) moveUnits(player *player, validator *inputValidator) error {
 		return err
 	}
 	
-	unitID := unitIDs[choice-1]
-	unit, exists := player.Units[unitID]
+	unit, exists := player.Units[unitIDs[choice-1]]
 	if !exists {
 		return errUnitNotFound
 	}
+	return g.promptMove(player, unit, validator)
+}
+
+// unitSummary describes a unit for menus and status lists.
+func unitSummary(u *unit) string {
+	summary := fmt.Sprintf("%s at (%d,%d), %s/%s moves", unitToString(u.Type), u.X, u.Y, formatMoves(u.Movement), formatMoves(u.maxMovement()))
+	if u.Health < maxHealth {
+		summary += fmt.Sprintf(", %d HP", u.Health)
+		if u.isWounded() {
+			summary += " 🩹 wounded"
+		}
+	}
+	if u.Order != orderNone {
+		summary += ", " + orderToString(u.Order)
+	}
+	if u.Destination != nil {
+		summary += fmt.Sprintf(" to (%d,%d)", u.Destination.X, u.Destination.Y)
+	}
+	if u.Job != jobNone {
+		summary += fmt.Sprintf(" %s (%d/%d turns)", jobToString(u.Job), u.JobProgress, jobTurns[u.Job])
+	}
+	if u.Experience > 0 {
+		summary += fmt.Sprintf(", %d xp", u.Experience)
+	}
+	if len(u.Promotions) > 0 {
+		names := make([]string, len(u.Promotions))
+		for i, p := range u.Promotions {
+			names[i] = promotionToString(p)
+		}
+		summary += " [" + strings.Join(names, ", ") + "]"
+	}
+	if u.canPromote() {
+		summary += " ⭐ promotion ready"
+	}
+	return summary
+}
+
+func (g *game) promptMove(player *player, unit *unit, validator *inputValidator) error {
+	unitID := unit.ID
+	fmt.Printf("Moving %s from (%d,%d) with %s moves left\n", unitToString(unit.Type), unit.X, unit.Y, formatMoves(unit.Movement))
+	
+	newX, err := validator.getIntInput("Enter new X coordinate: ", 0, g.Settings.MapWidth-1)
+	if err != nil {
+		return err
+	}
+	
+	newY, err := validator.getIntInput("Enter new Y coordinate: ", 0, g.Settings.MapHeight-1)
+	if err != nil {
+		return err
+	}
+	
+	result, err := g.MoveUnit(player.ID, unitID, newX, newY)
+	if errors.Is(err, errOutOfRange) || errors.Is(err, errNoMovesLeft) {
+		// Too far for one turn: walk there over several.
+		result, err = g.GoTo(player.ID, unitID, newX, newY)
+		if err != nil {
+			return err
+		}
+		if unit.Destination != nil {
+			fmt.Printf("%s is at (%d,%d) and will carry on to (%d,%d) next turn\n", unitToString(unit.Type), result.X, result.Y, newX, newY)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if result.Combat != nil {
+		g.displayCombat(*result.Combat)
+		return nil
+	}
+	if result.CapturedCity != nil {
+		return g.conquestMenu(player, result.CapturedCity, validator)
+	}
+	fmt.Printf("%s moved to (%d,%d)\n", unitToString(unit.Type), result.X, result.Y)
+	return nil
+}
+
+// conquestMenu lets the player keep or raze a city they have just taken.
+func (g *game) conquestMenu(player *player, city *city, validator *inputValidator) error {
+	fmt.Printf("🏴 You captured %s (Pop: %d)!\n", city.Name, city.Population)
+	choice, err := validator.getChoiceInput("What should become of it?", []string{"Keep the City", "Raze It"})
+	if err != nil {
+		return err
+	}
+	if choice == 2 {
+		if err := g.RazeCity(player.ID, city.ID); err != nil {
+			return err
+		}
+		fmt.Printf("🔥 %s was razed\n", city.Name)
+	}
+	return nil
+}
+
+// idleUnitsMenu offers any promotions waiting, then hands out orders to
+// each idle unit in turn until none are left or the player goes back.
+func (g *game) idleUnitsMenu(player *player, validator *inputValidator) error {
+	orders := []unitOrder{orderFortify, orderSentry, orderSkip, orderExplore, orderAutomate}
+	orderOptions := []string{"Fortify", "Sentry", "Skip Turn", "Explore", "Automate"}
+	
+	// Promotions first, whatever the unit is doing
+	for _, unit := range player.sortedUnits() {
+		if unit.canPromote() {
+			fmt.Printf("\n⭐ %s\n", unitSummary(unit))
+			if err := g.promoteMenu(player, unit, validator); err != nil {
+				fmt.Printf("Promotion error: %v\n", err)
+			}
+		}
+	}
+	
+	for {
+		idle, err := g.IdleUnits(player.ID)
+		if err != nil {
+			return err
+		}
+		if len(idle) == 0 {
+			fmt.Println("✅ Every unit has its orders")
+			return nil
+		}
+		
+		unit := idle[0]
+		options := append([]string{"Move"}, orderOptions...)
+		if unit.Type == unitWorker {
+			options = append(options, "Build Improvement")
+		}
+		options = append(options, "Back to Menu")
+		
+		fmt.Printf("\n🪖 %s (%d idle)\n", unitSummary(unit), len(idle))
+		choice, err := validator.getChoiceInput("Orders:", options)
+		if err != nil {
+			return err
+		}
+		
+		switch {
+		case choice == 1:
+			if err := g.promptMove(player, unit, validator); err != nil {
+				fmt.Printf("Unit movement error: %v\n", err)
+			}
+		case choice <= len(orders)+1:
+			if err := g.SetOrder(player.ID, unit.ID, orders[choice-2]); err != nil {
+				fmt.Printf("Order error: %v\n", err)
+			}
+		case options[choice-1] == "Build Improvement":
+			if err := g.buildMenu(player, unit, validator); err != nil {
+				fmt.Printf("Build error: %v\n", err)
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// buildMenu offers the jobs the worker can do where it stands.
+func (g *game) buildMenu(player *player, unit *unit, validator *inputValidator) error {
+	var jobs []workerJob
+	var options []string
+	for job := jobFarm; job < jobCount; job++ {
+		if g.canDoJob(player, unit.X, unit.Y, job) == nil {
+			jobs = append(jobs, job)
+			options = append(options, fmt.Sprintf("%s (%d turns)", jobToString(job), jobTurns[job]))
+		}
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("%w: nothing to build at (%d,%d)", errCannotImprove, unit.X, unit.Y)
+	}
+	
+	choice, err := validator.getChoiceInput("\n🛠️ Choose an Improvement:", options)
+	if err != nil {
+		return err
+	}
+	if err := g.BuildImprovement(player.ID, unit.ID, jobs[choice-1]); err != nil {
+		return err
+	}
+	fmt.Printf("%s started a %s at (%d,%d)\n", unitToString(unit.Type), jobToString(jobs[choice-1]), unit.X, unit.Y)
+	return nil
+}
+
+var promotionDescriptions = [promotionCount]string{
+	promotionCombat:   fmt.Sprintf("+%d%% strength", combatPromotionBonus),
+	promotionRanger:   fmt.Sprintf("+%d%% strength in forest, jungle and hills", rangerPromotionBonus),
+	promotionMobility: "+1 movement",
+	promotionRally:    fmt.Sprintf("regain %d health after destroying a unit", rallyHealing),
+}
+
+func (g *game) promoteMenu(player *player, unit *unit, validator *inputValidator) error {
+	if !unit.canPromote() {
+		return errNoPromotion
+	}
+	
+	var choices []promotionType
+	var options []string
+	for p := promotionType(0); p < promotionCount; p++ {
+		if !unit.hasPromotion(p) {
+			choices = append(choices, p)
+			options = append(options, fmt.Sprintf("%s (%s)", promotionToString(p), promotionDescriptions[p]))
+		}
+	}
+	if len(choices) == 0 {
+		return errPromotionKnown
+	}
 	
-	fmt.Printf("Moving %s from (%d,%d)\n", unitToString(unit.Type), unit.X, unit.Y)
+	choice, err := validator.getChoiceInput("\n⭐ Choose a Promotion:", options)
+	if err != nil {
+		return err
+	}
+	if err := g.Promote(player.ID, unit.ID, choices[choice-1]); err != nil {
+		return err
+	}
+	fmt.Printf("%s promoted: %s\n", unitToString(unit.Type), promotionToString(choices[choice-1]))
+	return nil
+}
+
+// moveUnit walks the unit to (newX, newY) one step at a time along the
+// cheapest route its owner knows of, paying each tile's movement cost. The
+// whole move is checked before the unit leaves, so a rejected move costs
+// nothing.
+func (g *game) moveUnit(unit *unit, newX, newY int) error {
+	if unit.Movement <= 0 {
+		return errNoMovesLeft
+	}
+	if !g.isValidTile(newX, newY) {
+		return errInvalidMove
+	}
 	
-	newX, err := validator.getIntInput("Enter new X coordinate: ", 0, mapWidth-1)
+	path, err := g.findPath(g.Players[unit.OwnerID], unit.X, unit.Y, newX, newY)
 	if err != nil {
 		return err
 	}
-	
-	newY, err := validator.getIntInput("Enter new Y coordinate: ", 0, mapHeight-1)
-	if err != nil {
-		return err
+	if len(path) == 0 {
+		return errInvalidMove
+	}
+	target := g.foreignCity(unit.OwnerID, newX, newY)
+	if target != nil {
+		if err := g.canCapture(unit, target); err != nil {
+			return err
+		}
+	}
+	left := unit.Movement
+	prev := tilePos{X: unit.X, Y: unit.Y}
+	for i, step := range path {
+		if !g.isValidTile(step.X, step.Y) {
+			return errInvalidMove
+		}
+		if g.Map[step.Y][step.X].UnitID != -1 {
+			return errTileOccupied
+		}
+		cost := g.moveCost(prev.X, prev.Y, step.X, step.Y)
+		prev = step
+		// A unit that hasn't moved yet can always take one step, so slow
+		// units aren't shut out of rough terrain.
+		if cost > left && !(i == 0 && left == unit.maxMovement()) {
+			return fmt.Errorf("%w: (%d,%d) needs %s more moves", errOutOfRange, newX, newY, formatMoves(cost-left))
+		}
+		left = max(left-cost, 0)
+	}
+	
+	// Clear old position
+	g.Map[unit.Y][unit.X].UnitID = -1
+	
+	// Set new position
+	unit.X, unit.Y = newX, newY
+	g.Map[newY][newX].UnitID = unit.ID
+	
+	unit.Movement = left
+	if target != nil {
+		g.captureCity(unit, target)
+	}
+	return nil
+}
+
+// moveCost is the movement points it takes to step from one tile onto
+// the next.
+func (g *game) moveCost(fromX, fromY, toX, toY int) int {
+	from, to := g.Map[fromY][fromX], g.Map[toY][toX]
+	return routeMoveCost(tileRoute(from.Route, from.CityID), tileRoute(to.Route, to.CityID), to.Terrain)
+}
+
+// tileRoute is the route on a tile; a city counts as a road.
+func tileRoute(route routeType, cityID int) routeType {
+	if route == routeNone && cityID != -1 {
+		return routeRoad
+	}
+	return route
+}
+
+// routeMoveCost is the cost of a step between tiles with the given routes
+// onto the given terrain. Roads and railroads only speed a unit up when
+// both tiles have them.
+func routeMoveCost(from, to routeType, terrain terrainType) int {
+	switch {
+	case from == routeRailroad && to == routeRailroad:
+		return railroadMoveCost
+	case from != routeNone && to != routeNone:
+		return roadMoveCost
+	}
+	return terrainMoveCost[terrain] * movementScale
+}
+
+// formatMoves renders movement points as whole and fractional moves.
+func formatMoves(points int) string {
+	whole, part := points/movementScale, points%movementScale
+	if part == 0 {
+		return strconv.Itoa(whole)
+	}
+	d, r := movementScale, part // reduce the fraction by their gcd
+	for r != 0 {
+		d, r = r, d%r
+	}
+	fraction := fmt.Sprintf("%d/%d", part/d, movementScale/d)
+	if whole == 0 {
+		return fraction
+	}
+	return fmt.Sprintf("%d %s", whole, fraction)
+}
+
+// refreshMovement restores every unit's movement points for the new turn.
+func (g *game) refreshMovement() {
+	for _, player := range g.Players {
+		for _, unit := range player.Units {
+			unit.Movement = unit.maxMovement()
+		}
+	}
+}
+
+// ========== Pathfinding ==========
+// pathNode is a tile on the A* open list.
+type pathNode struct {
+	index    int // y*width + x
+	priority int // cost so far plus the estimate to the goal
+}
+
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any) { *q = append(*q, x.(pathNode)) }
+func (q *pathQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// findPath returns the cheapest route from (fromX, fromY) to (toX, toY)
+// over the map as p knows it, excluding the start. Unexplored tiles are
+// assumed to be open ground, and only units p can see block the way, so a
+// route may turn out to be blocked once the unit gets closer.
+func (g *game) findPath(p *player, fromX, fromY, toX, toY int) ([]tilePos, error) {
+	w, h := g.Settings.MapWidth, g.Settings.MapHeight
+	start, goal := fromY*w+fromX, toY*w+toX
+	
+	// stepCost is the cost of a step between neighbouring tiles, or 0 if p
+	// thinks the second can't be entered.
+	stepCost := func(fx, fy, x, y int) int {
+		view, err := g.knownTile(p, x, y)
+		if err != nil {
+			return 0
+		}
+		if !view.Explored {
+			return movementScale
+		}
+		if !view.Terrain.isPassable() || view.UnitID != -1 {
+			return 0
+		}
+		// Foreign cities can't be passed through, and can only be marched
+		// into to take them from an enemy.
+		if view.CityID != -1 && view.CityOwnerID != p.ID && (x != toX || y != toY || g.stance(p.ID, view.CityOwnerID) != stanceWar) {
+			return 0
+		}
+		fromView, _ := g.knownTile(p, fx, fy)
+		return routeMoveCost(tileRoute(fromView.Route, fromView.CityID), tileRoute(view.Route, view.CityID), view.Terrain)
+	}
+	if stepCost(fromX, fromY, toX, toY) == 0 {
+		return nil, fmt.Errorf("%w: (%d,%d)", errNoPath, toX, toY)
+	}
+	// estimate assumes roads the whole way. That can overestimate along
+	// railroads and miss the very cheapest route there, but keeps the
+	// search narrow.
+	estimate := func(x, y int) int {
+		return g.distance(x, y, toX, toY) * roadMoveCost
+	}
+	
+	cost := make([]int, w*h)
+	from := make([]int, w*h)
+	for i := range cost {
+		cost[i] = -1
+	}
+	cost[start] = 0
+	open := &pathQueue{{index: start, priority: estimate(fromX, fromY)}}
+	
+	for open.Len() > 0 {
+		current := heap.Pop(open).(pathNode)
+		if current.index == goal {
+			break
+		}
+		cx, cy := current.index%w, current.index/w
+		// Skip entries superseded by a cheaper route
+		if current.priority-estimate(cx, cy) > cost[current.index] {
+			continue
+		}
+		
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				nx, ny := g.wrap(cx+dx, cy+dy)
+				step := stepCost(cx, cy, nx, ny)
+				if step == 0 {
+					continue
+				}
+				next := ny*w + nx
+				newCost := cost[current.index] + step
+				if cost[next] != -1 && newCost >= cost[next] {
+					continue
+				}
+				cost[next] = newCost
+				from[next] = current.index
+				heap.Push(open, pathNode{index: next, priority: newCost + estimate(nx, ny)})
+			}
+		}
+	}
+	
+	if cost[goal] == -1 {
+		return nil, fmt.Errorf("%w: (%d,%d)", errNoPath, toX, toY)
+	}
+	var path []tilePos
+	for i := goal; i != start; i = from[i] {
+		path = append(path, tilePos{X: i % w, Y: i / w})
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// followGoTo walks the unit toward its destination as far as its movement
+// allows this turn, finding the route again from where it stands so it
+// steers around whatever it has discovered since. The destination is
+// dropped on arrival or when no route is left, and with it a go-to order.
+func (g *game) followGoTo(u *unit) {
+	if u.Destination == nil {
+		return
+	}
+	owner := g.Players[u.OwnerID]
+	dest := *u.Destination
+	
+	for u.Movement > 0 && (u.X != dest.X || u.Y != dest.Y) {
+		path, err := g.findPath(owner, u.X, u.Y, dest.X, dest.Y)
+		if err != nil {
+			u.Destination = nil
+			if u.Order == orderGoTo {
+				u.Order = orderNone
+				g.logEvent(owner.ID, "🧭 %s at (%d,%d) can't find a way to (%d,%d)", unitToString(u.Type), u.X, u.Y, dest.X, dest.Y)
+			}
+			return
+		}
+		if err := g.moveUnit(u, path[0].X, path[0].Y); err != nil {
+			// Out of moves for the turn, or blocked; try again next turn.
+			break
+		}
+		g.updateVisibility(owner)
+	}
+	
+	if u.X == dest.X && u.Y == dest.Y {
+		u.Destination = nil
+		if u.Order == orderGoTo {
+			u.Order = orderNone
+			g.logEvent(owner.ID, "🧭 %s reached (%d,%d)", unitToString(u.Type), dest.X, dest.Y)
+		}
+	}
+}
+
+// setNearestDestination points the unit at the closest of targets its
+// owner knows a way to. Only the few nearest are tried, so targets that
+// can't be reached, such as other continents, don't each cost a search of
+// the whole map.
+func (g *game) setNearestDestination(u *unit, targets []tilePos) bool {
+	sort.SliceStable(targets, func(i, j int) bool {
+		return g.distance(u.X, u.Y, targets[i].X, targets[i].Y) < g.distance(u.X, u.Y, targets[j].X, targets[j].Y)
+	})
+	owner := g.Players[u.OwnerID]
+	for i := 0; i < len(targets) && i < destinationTries; i++ {
+		if _, err := g.findPath(owner, u.X, u.Y, targets[i].X, targets[i].Y); err == nil {
+			u.Destination = &tilePos{X: targets[i].X, Y: targets[i].Y}
+			return true
+		}
+	}
+	return false
+}
+
+// unexploredTiles lists every tile the player has never seen.
+func (g *game) unexploredTiles(p *player) []tilePos {
+	var tiles []tilePos
+	for y, row := range p.Memory {
+		for x, memory := range row {
+			if !memory.Explored {
+				tiles = append(tiles, tilePos{X: x, Y: y})
+			}
+		}
+	}
+	return tiles
+}
+
+// ========== Unit Orders ==========
+// An order keeps a unit busy until it is told otherwise: a skipped turn
+// lasts until the owner's next turn, a go-to until the unit arrives, a
+// sentry until a foreign unit comes into view, and explore until there is
+// nothing left it can reach. Units without an order are idle.
+const (
+	sentryRadius     = 2
+	destinationTries = 5
+)
+
+// isIdle reports whether the unit is waiting for orders this turn.
+func (u *unit) isIdle() bool {
+	return u.Order == orderNone && u.Movement > 0
+}
+
+// carryOutOrders runs the player's standing orders at the start of their
+// turn.
+func (g *game) carryOutOrders(p *player) {
+	for _, u := range p.sortedUnits() {
+		// An automated attack may have cost the player this unit.
+		if _, alive := p.Units[u.ID]; alive {
+			g.carryOutOrder(p, u)
+		}
+	}
+}
+
+func (g *game) carryOutOrder(p *player, u *unit) {
+	switch u.Order {
+	case orderSkip:
+		u.Order = orderNone
+	case orderSentry:
+		if g.foreignUnitInSight(p, u) {
+			u.Order = orderNone
+			g.logEvent(p.ID, "👀 Sentry %s at (%d,%d) spotted a foreign unit", unitToString(u.Type), u.X, u.Y)
+		}
+	case orderGoTo:
+		g.followGoTo(u)
+	case orderExplore:
+		g.explore(p, u)
+	case orderAutomate:
+		g.automateUnit(p, u)
+	case orderBuild:
+		if g.work(p, u) {
+			u.Order = orderNone
+		}
+	}
+}
+
+// foreignUnitInSight reports whether the player can see another
+// civilization's unit near u.
+func (g *game) foreignUnitInSight(p *player, u *unit) bool {
+	for dy := -sentryRadius; dy <= sentryRadius; dy++ {
+		for dx := -sentryRadius; dx <= sentryRadius; dx++ {
+			x, y := g.wrap(u.X+dx, u.Y+dy)
+			view, err := g.knownTile(p, x, y)
+			if err == nil && view.UnitID != -1 && view.UnitOwnerID != p.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// explore walks the unit from one nearest unexplored tile to the next until
+// its movement runs out, and drops the order once nothing it can reach is
+// left unexplored.
+func (g *game) explore(p *player, u *unit) {
+	for u.Movement > 0 {
+		if u.Destination == nil && !g.setNearestDestination(u, g.unexploredTiles(p)) {
+			u.Order = orderNone
+			g.logEvent(p.ID, "🧭 %s at (%d,%d) has nothing left to explore", unitToString(u.Type), u.X, u.Y)
+			return
+		}
+		moves := u.Movement
+		g.followGoTo(u)
+		if u.Destination != nil || u.Movement == moves {
+			return
+		}
+	}
+}
+
+// automateUnit plays the unit the way the AI plays its own: it rests while
+// wounded, attacks adjacent enemies it can beat, marches on enemy cities
+// it can reach, founds cities on good sites, and otherwise explores or
+// heads for a city site. Workers improve their cities' tiles.
+func (g *game) automateUnit(p *player, u *unit) {
+	if u.Type == unitWorker {
+		g.automateWorker(p, u)
+		return
+	}
+	// Wounded units stay put until they have healed
+	if u.isWounded() {
+		return
+	}
+	if u.Movement > 0 && !u.Type.isCivilian() {
+		if enemy, ok := g.findAdjacentEnemy(u); ok && g.attackStrength(u, enemy.Terrain) >= estimatedDefense(enemy) {
+			if _, err := g.MoveUnit(p.ID, u.ID, enemy.X, enemy.Y); err == nil {
+				return
+			}
+		}
+	}
+	if u.Destination == nil && !u.Type.isCivilian() {
+		// Take an undefended enemy city if one is within reach
+		if g.setNearestDestination(u, g.aiCaptureTargets(p)) {
+			g.followGoTo(u)
+			return
+		}
+	}
+	if u.Type == unitSettler && u.Destination == nil && g.aiGoodCitySite(p, u.X, u.Y, g.knownCities(p)) {
+		name := fmt.Sprintf("%s %d", p.Name, g.NextCityID)
+		if city, err := g.FoundCity(p.ID, u.ID, name); err == nil {
+			g.logEvent(p.ID, "🏙️ %s founded %s at (%d,%d)", p.Name, city.Name, city.X, city.Y)
+			return
+		}
+	}
+	if u.Destination == nil {
+		targets := g.unexploredTiles(p)
+		if u.Type == unitSettler {
+			targets = g.citySites(p)
+		}
+		if !g.setNearestDestination(u, targets) {
+			return
+		}
+	}
+	g.followGoTo(u)
+}
+
+// ========== Tile Improvements ==========
+// Workers improve the tile they stand on. A job takes several turns of
+// work, each spending the worker's whole turn, and is abandoned if the
+// worker moves or is given another order first.
+
+// jobTurns is how many turns of work each job takes.
+var jobTurns = [jobCount]int{
+	jobRoad:     2,
+	jobFarm:     4,
+	jobMine:     6,
+	jobRailroad: 6,
+}
+
+// jobImprovements is the improvement each farming or mining job builds.
+var jobImprovements = [jobCount]improvementType{
+	jobFarm: improvementFarm,
+	jobMine: improvementMine,
+}
+
+// improvementTerrains marks the terrain each improvement can be built on.
+var improvementTerrains = [improvementCount][terrainCount]bool{
+	improvementFarm: {terrainPlains: true, terrainDesert: true, terrainTundra: true, terrainHills: true},
+	improvementMine: {terrainHills: true, terrainDesert: true},
+}
+
+// setOrder gives the unit a new order, abandoning wherever it was heading
+// and whatever it was building.
+func (u *unit) setOrder(order unitOrder) {
+	u.Order, u.Destination = order, nil
+	u.Job, u.JobProgress = jobNone, 0
+}
+
+// canDoJob reports why the player's worker couldn't do the job on (x, y),
+// or nil if it could.
+func (g *game) canDoJob(p *player, x, y int, job workerJob) error {
+	tile := g.Map[y][x]
+	return g.jobAllowed(p, tileView{
+		X: x, Y: y, Explored: true, Visible: true,
+		Terrain: tile.Terrain, Improvement: tile.Improvement, Route: tile.Route,
+		CityID: tile.CityID, OwnerID: tile.OwnerID,
+	}, job)
+}
+
+// jobAllowed is canDoJob on a tile as it is known: the game checks it
+// against the map itself, automated workers against what their owner
+// has seen.
+func (g *game) jobAllowed(p *player, tile tileView, job workerJob) error {
+	if tile.CityID != -1 {
+		return fmt.Errorf("%w: cities can't be improved", errCannotImprove)
+	}
+	if tile.OwnerID != -1 && tile.OwnerID != p.ID {
+		return fmt.Errorf("%w: the tile belongs to %s", errCannotImprove, g.Players[tile.OwnerID].Name)
+	}
+	
+	switch job {
+	case jobFarm, jobMine:
+		improvement := jobImprovements[job]
+		if !improvementTerrains[improvement][tile.Terrain] {
+			return fmt.Errorf("%w: no %s on %s", errCannotImprove, jobToString(job), terrainToString(tile.Terrain))
+		}
+		if tile.Improvement == improvement {
+			return fmt.Errorf("%w: the tile already has a %s", errCannotImprove, jobToString(job))
+		}
+	case jobRoad:
+		if tile.Route != routeNone {
+			return fmt.Errorf("%w: the tile already has a road", errCannotImprove)
+		}
+	case jobRailroad:
+		if tile.Route == routeRailroad {
+			return fmt.Errorf("%w: the tile already has a railroad", errCannotImprove)
+		}
+		if tile.Route != routeRoad {
+			return fmt.Errorf("%w: railroads are laid over roads", errCannotImprove)
+		}
+		if !p.Techs[techIndustrialization] {
+			return fmt.Errorf("%w: railroads need %s", errItemLocked, techToString(techIndustrialization))
+		}
+	default:
+		return fmt.Errorf("%w: unknown job %d", errInvalidInput, job)
+	}
+	return nil
+}
+
+// work spends the worker's turn on its job and reports whether the job is
+// over, either finished or no longer possible.
+func (g *game) work(p *player, u *unit) bool {
+	if u.Job == jobNone {
+		return true
+	}
+	if u.Movement <= 0 {
+		return false
+	}
+	if err := g.canDoJob(p, u.X, u.Y, u.Job); err != nil {
+		g.logEvent(p.ID, "🛠️ %s at (%d,%d) stopped work: %v", unitToString(u.Type), u.X, u.Y, err)
+		u.Job, u.JobProgress = jobNone, 0
+		return true
+	}
+	
+	u.Movement = 0
+	u.JobProgress++
+	if u.JobProgress < jobTurns[u.Job] {
+		return false
+	}
+	g.completeJob(u.X, u.Y, u.Job)
+	g.logEvent(p.ID, "🛠️ %s built a %s at (%d,%d)", unitToString(u.Type), jobToString(u.Job), u.X, u.Y)
+	u.Job, u.JobProgress = jobNone, 0
+	return true
+}
+
+// completeJob puts the job's improvement or route on the tile. A farm
+// replaces a mine and a mine a farm.
+func (g *game) completeJob(x, y int, job workerJob) {
+	tile := &g.Map[y][x]
+	switch job {
+	case jobFarm, jobMine:
+		tile.Improvement = jobImprovements[job]
+	case jobRoad:
+		tile.Route = routeRoad
+	case jobRailroad:
+		tile.Route = routeRailroad
+	}
+}
+
+// bestJob picks what an automated worker should do on (x, y), going by
+// what the player knows of the tile: a mine on hills and a farm elsewhere
+// on bare tiles, then a road, then a railroad.
+func (g *game) bestJob(p *player, x, y int) (workerJob, bool) {
+	view, err := g.knownTile(p, x, y)
+	if err != nil || !view.Explored {
+		return jobNone, false
+	}
+	jobs := []workerJob{jobFarm, jobMine, jobRoad, jobRailroad}
+	if view.Terrain == terrainHills {
+		jobs[0], jobs[1] = jobMine, jobFarm
+	}
+	for _, job := range jobs {
+		if (job == jobFarm || job == jobMine) && view.Improvement != improvementNone {
+			continue
+		}
+		if g.jobAllowed(p, view, job) == nil {
+			return job, true
+		}
+	}
+	return jobNone, false
+}
+
+// automateWorker improves the tiles the player's cities work: the one it
+// stands on if there is something to do there, otherwise the nearest one
+// that needs it.
+func (g *game) automateWorker(p *player, u *unit) {
+	if u.Job != jobNone {
+		g.work(p, u)
+		return
+	}
+	
+	worked := make(map[tilePos]bool)
+	for _, city := range p.Cities {
+		for _, pos := range city.WorkedTiles {
+			worked[pos] = true
+		}
+	}
+	if u.Destination == nil && worked[tilePos{X: u.X, Y: u.Y}] {
+		if job, ok := g.bestJob(p, u.X, u.Y); ok {
+			u.Job = job
+			g.work(p, u)
+			return
+		}
+	}
+	if u.Destination == nil {
+		var targets []tilePos
+		for pos := range worked {
+			if view, err := g.knownTile(p, pos.X, pos.Y); err != nil || view.UnitID != -1 {
+				continue
+			}
+			if _, ok := g.bestJob(p, pos.X, pos.Y); ok {
+				targets = append(targets, pos)
+			}
+		}
+		// Map order is random; sort so the choice doesn't depend on it.
+		sort.Slice(targets, func(i, j int) bool {
+			if targets[i].Y != targets[j].Y {
+				return targets[i].Y < targets[j].Y
+			}
+			return targets[i].X < targets[j].X
+		})
+		if !g.setNearestDestination(u, targets) {
+			return
+		}
+	}
+	g.followGoTo(u)
+}
+
+// ========== Combat ==========
+type combatResult struct {
+	AttackerType      unitType
+	DefenderType      unitType
+	DefenderOwnerID   int
+	AttackStrength    int
+	DefenseStrength   int
+	Rounds            int
+	AttackerHealth    int
+	DefenderHealth    int
+	AttackerDestroyed bool
+	DefenderDestroyed bool
+}
+
+// distance returns the number of king moves between two tiles on the
+// wrapping map.
+func (g *game) distance(x1, y1, x2, y2 int) int {
+	dx := abs(x1 - x2)
+	dx = min(dx, g.Settings.MapWidth-dx)
+	dy := abs(y1 - y2)
+	dy = min(dy, g.Settings.MapHeight-dy)
+	return max(dx, dy)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// attackStrength is the attacker's strength in a fight on the given
+// terrain, raised by its promotions and worn down by its wounds.
+func (g *game) attackStrength(attacker *unit, terrain terrainType) int {
+	strength := attacker.Strength * (100 + attacker.promotionBonus(terrain))
+	return attacker.healthScaled(strength)
+}
+
+// defenseStrength scales the defender by the terrain it stands on, its
+// promotions, the fortified position of a city and its Walls, and its
+// wounds.
+func (g *game) defenseStrength(u *unit) int {
+	tile := g.Map[u.Y][u.X]
+	bonus := 100 + terrainDefenseBonus[tile.Terrain] + u.promotionBonus(tile.Terrain)
+	if u.Order == orderFortify {
+		bonus += fortifyDefenseBonus
+	}
+	if tile.CityID != -1 {
+		bonus += cityDefenseBonus
+		if city, err := g.findCity(tile.CityID); err == nil {
+			bonus += city.effects().DefenseBonus
+		}
+	}
+	return u.healthScaled(u.Strength * bonus)
+}
+
+func (g *game) attack(attacker, defender *unit) (combatResult, error) {
+	if attacker.Type.isCivilian() {
+		return combatResult{}, errCannotAttack
+	}
+	if attacker.Movement <= 0 {
+		return combatResult{}, errNoMovesLeft
+	}
+	if g.distance(attacker.X, attacker.Y, defender.X, defender.Y) != 1 {
+		return combatResult{}, errNotAdjacent
+	}
+	
+	result := g.resolveCombat(attacker, defender)
+	attacker.Movement = 0
+	
+	attackerOwner, defenderOwner := g.Players[attacker.OwnerID], g.Players[defender.OwnerID]
+	attackerOwner.WarWeariness++
+	defenderOwner.WarWeariness++
+	g.adjustRelation(defenderOwner.ID, attackerOwner.ID, -10)
+	switch {
+	case result.DefenderDestroyed:
+		g.removeUnit(defender)
+		g.logEvent(attacker.OwnerID, "⚔️ %s %s destroyed %s %s", attackerOwner.Name, unitToString(result.AttackerType), defenderOwner.Name, unitToString(result.DefenderType))
+		g.rewardVictor(attacker, experienceForAttack)
+		g.checkElimination(defenderOwner)
+	case result.AttackerDestroyed:
+		g.removeUnit(attacker)
+		g.logEvent(defender.OwnerID, "🛡️ %s %s repelled %s %s", defenderOwner.Name, unitToString(result.DefenderType), attackerOwner.Name, unitToString(result.AttackerType))
+		g.rewardVictor(defender, experienceForDefense)
+	}
+	return result, nil
+}
+
+// resolveCombat fights rounds until one unit runs out of health. Each
+// round goes to the attacker with probability attack/(attack+defense) and
+// costs the loser combatBaseDamage plus up to combatDamageRange-1 health.
+func (g *game) resolveCombat(attacker, defender *unit) combatResult {
+	result := combatResult{
+		AttackerType:    attacker.Type,
+		DefenderType:    defender.Type,
+		DefenderOwnerID: defender.OwnerID,
+		AttackStrength:  g.attackStrength(attacker, g.Map[defender.Y][defender.X].Terrain),
+		DefenseStrength: g.defenseStrength(defender),
+	}
+	
+	for attacker.Health > 0 && defender.Health > 0 {
+		damage := combatBaseDamage + g.rng.IntN(combatDamageRange)
+		if g.rng.IntN(result.AttackStrength+result.DefenseStrength) < result.AttackStrength {
+			defender.Health = max(defender.Health-damage, 0)
+		} else {
+			attacker.Health = max(attacker.Health-damage, 0)
+		}
+		result.Rounds++
+	}
+	
+	result.AttackerHealth = attacker.Health
+	result.DefenderHealth = defender.Health
+	result.AttackerDestroyed = attacker.Health == 0
+	result.DefenderDestroyed = defender.Health == 0
+	return result
+}
+
+// ========== Promotions ==========
+const (
+	experienceForAttack   = 5
+	experienceForDefense  = 4
+	combatPromotionBonus  = 25 // percent strength in every fight
+	rangerPromotionBonus  = 50 // percent strength fighting on rough terrain
+	rallyHealing          = 50 // health regained after destroying a unit
+)
+
+// promotionThresholds is the experience at which each successive promotion
+// is earned. Units from a city with Barracks start with their first.
+var promotionThresholds = []int{10, 25, 45, 70}
+
+func (u *unit) hasPromotion(p promotionType) bool {
+	for _, have := range u.Promotions {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingPromotions is how many promotions the unit has earned but not yet
+// been given.
+func (u *unit) pendingPromotions() int {
+	earned := 0
+	for _, threshold := range promotionThresholds {
+		if u.Experience >= threshold {
+			earned++
+		}
+	}
+	return max(earned-len(u.Promotions), 0)
+}
+
+// canPromote reports whether the unit has a promotion waiting. Civilians
+// never fight, so they are never promoted.
+func (u *unit) canPromote() bool {
+	return !u.Type.isCivilian() && u.pendingPromotions() > 0
+}
+
+func (u *unit) promote(p promotionType) {
+	u.Promotions = append(u.Promotions, p)
+	if p == promotionMobility {
+		u.Movement += movementScale
+	}
+}
+
+// maxMovement is the movement points the unit starts each turn with.
+func (u *unit) maxMovement() int {
+	movement := unitStats[u.Type].Movement
+	if u.hasPromotion(promotionMobility) {
+		movement++
+	}
+	return movement * movementScale
+}
+
+// promotionBonus is the percentage the unit's promotions add to its
+// strength in a fight on the given terrain. Rough terrain is the terrain
+// that helps defenders.
+func (u *unit) promotionBonus(terrain terrainType) int {
+	bonus := 0
+	if u.hasPromotion(promotionCombat) {
+		bonus += combatPromotionBonus
+	}
+	if u.hasPromotion(promotionRanger) && terrainDefenseBonus[terrain] > 0 {
+		bonus += rangerPromotionBonus
+	}
+	return bonus
+}
+
+// rewardVictor gives the surviving unit of a fight its experience, and the
+// health a Rally promotion brings back.
+func (g *game) rewardVictor(u *unit, experience int) {
+	if u.hasPromotion(promotionRally) {
+		u.Health = min(u.Health+rallyHealing, maxHealth)
+	}
+	u.Experience += experience
+	g.offerPromotion(u)
+}
+
+// offerPromotion lets the AI pick promotions as soon as they are earned,
+// and tells a human player one is waiting.
+func (g *game) offerPromotion(u *unit) {
+	if !u.canPromote() {
+		return
+	}
+	owner := g.Players[u.OwnerID]
+	if !owner.IsAI {
+		g.logEvent(owner.ID, "⭐ %s at (%d,%d) has earned a promotion", unitToString(u.Type), u.X, u.Y)
+		return
+	}
+	for u.canPromote() {
+		p, ok := g.aiChoosePromotion(u)
+		if !ok {
+			return
+		}
+		u.promote(p)
+	}
+}
+
+// aiChoosePromotion takes Ranger for units standing in rough terrain and
+// otherwise the first promotion the unit lacks.
+func (g *game) aiChoosePromotion(u *unit) (promotionType, bool) {
+	if terrainDefenseBonus[g.Map[u.Y][u.X].Terrain] > 0 && !u.hasPromotion(promotionRanger) {
+		return promotionRanger, true
+	}
+	for p := promotionType(0); p < promotionCount; p++ {
+		if !u.hasPromotion(p) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// ========== Unit Health ==========
+// Units heal only on turns they stay put, fastest in their own cities.
+const (
+	maxHealth           = 100
+	woundedHealth       = 50 // below this a unit is flagged for pulling back
+	healInCity          = 30
+	healInTerritory     = 15
+	healInField         = 5
+	healFortifiedBonus  = 10
+)
+
+// healthScaled scales a strength by the unit's remaining health, keeping
+// at least 1 so a badly wounded unit can still win a round.
+func (u *unit) healthScaled(strength int) int {
+	return max(strength*u.Health/maxHealth, 1)
+}
+
+func (u *unit) isWounded() bool {
+	return u.Health < woundedHealth
+}
+
+// healRate is how much health the unit regains this turn where it stands.
+func (g *game) healRate(u *unit) int {
+	tile := g.Map[u.Y][u.X]
+	rate := healInField
+	switch {
+	case tile.CityID != -1:
+		if c, err := g.findCity(tile.CityID); err == nil && c.OwnerID == u.OwnerID {
+			rate = healInCity
+		}
+	case tile.OwnerID == u.OwnerID:
+		rate = healInTerritory
+	}
+	if u.Order == orderFortify {
+		rate += healFortifiedBonus
+	}
+	return rate
+}
+
+// healUnits heals every unit that didn't spend any movement this turn. It
+// runs before movement is refreshed for the next.
+func (g *game) healUnits() {
+	for _, player := range g.Players {
+		for _, u := range player.sortedUnits() {
+			if u.Health < maxHealth && u.Movement == u.maxMovement() {
+				u.Health = min(u.Health+g.healRate(u), maxHealth)
+			}
+		}
+	}
+}
+
+// removeUnit takes a unit off the map and out of its owner's army.
+func (g *game) removeUnit(u *unit) {
+	if g.Map[u.Y][u.X].UnitID == u.ID {
+		g.Map[u.Y][u.X].UnitID = -1
+	}
+	owner := g.Players[u.OwnerID]
+	if _, exists := owner.Units[u.ID]; exists {
+		delete(owner.Units, u.ID)
+		owner.UnitCount--
+	}
+}
+
+// findAdjacentEnemy returns the tile of a unit next to u, in sight of u's
+// owner, whose owner is at war with them, if any.
+func (g *game) findAdjacentEnemy(u *unit) (tileView, bool) {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			x, y := g.wrap(u.X+dx, u.Y+dy)
+			view, err := g.knownTile(g.Players[u.OwnerID], x, y)
+			if err != nil || view.UnitID == -1 || view.UnitOwnerID == u.OwnerID {
+				continue
+			}
+			if g.stance(u.OwnerID, view.UnitOwnerID) == stanceWar {
+				return view, true
+			}
+		}
+	}
+	return tileView{}, false
+}
+
+// estimatedDefense is how strong an enemy unit looks from outside: its
+// type on its terrain, in a city if it is in one. Its wounds, promotions,
+// fortification and the city's Walls can't be seen, so it is taken to be
+// at full health with none of them.
+func estimatedDefense(view tileView) int {
+	bonus := 100 + terrainDefenseBonus[view.Terrain]
+	if view.CityID != -1 {
+		bonus += cityDefenseBonus
+	}
+	return unitStats[view.UnitType].Strength * bonus
+}
+
+func (g *game) displayCombat(result combatResult) {
+	fmt.Printf("⚔️ %s attacked %s %s (strength %d vs %d, %d rounds)\n",
+		unitToString(result.AttackerType), g.Players[result.DefenderOwnerID].Name, unitToString(result.DefenderType),
+		result.AttackStrength/100, result.DefenseStrength/100, result.Rounds)
+	switch {
+	case result.DefenderDestroyed:
+		fmt.Printf("Victory! Enemy destroyed. Your %s has %d health left.\n", unitToString(result.AttackerType), result.AttackerHealth)
+	case result.AttackerDestroyed:
+		fmt.Printf("Defeat! Your %s was destroyed. The defender has %d health left.\n", unitToString(result.AttackerType), result.DefenderHealth)
+	}
+}
+
+// ========== Diplomacy ==========
+func (g *game) stance(a, b int) diplomaticStance {
+	return g.Players[a].Treaties[b].Stance
+}
+
+func (g *game) setStance(a, b int, stance diplomaticStance) {
+	t := treaty{Stance: stance}
+	if stance == stanceCeasefire {
+		t.TurnsLeft = ceasefireTurns
+	}
+	g.Players[a].Treaties[b] = t
+	g.Players[b].Treaties[a] = t
+}
+
+// adjustRelation changes how player regards other, within [-100, 100].
+func (g *game) adjustRelation(playerID, otherID, delta int) {
+	relations := g.Players[playerID].Relations
+	relations[otherID] = max(-100, min(100, relations[otherID]+delta))
+}
+
+// acceptsProposal decides whether a player would agree to a treaty. Tired
+// civilizations take a ceasefire more readily; peace needs goodwill.
+func (g *game) acceptsProposal(player *player, fromID int, proposal proposalType) bool {
+	relation := player.Relations[fromID]
+	switch proposal {
+	case proposeCeasefire:
+		return relation+player.WarWeariness*5 >= -30
+	case proposePeace:
+		if g.stance(player.ID, fromID) == stanceCeasefire {
+			return relation >= 0
+		}
+		return relation >= 30
+	}
+	return false
+}
+
+// updateDiplomacy runs once a year. Ceasefires tick down and lapse back
+// into war. Civilizations whose units press against each other's cities
+// grow wary, while those without contact slowly warm up.
+func (g *game) updateDiplomacy() {
+	for i, a := range g.Players {
+		for j := i + 1; j < len(g.Players); j++ {
+			b := g.Players[j]
+			t := a.Treaties[b.ID]
+			if t.Stance == stanceCeasefire {
+				t.TurnsLeft--
+				if t.TurnsLeft <= 0 {
+					g.setStance(a.ID, b.ID, stanceWar)
+					g.logEvent(-1, "⚔️ The ceasefire between %s and %s has expired", a.Name, b.Name)
+				} else {
+					a.Treaties[b.ID], b.Treaties[a.ID] = t, t
+				}
+			}
+			
+			if g.inBorderContact(a, b) || g.inBorderContact(b, a) {
+				g.adjustRelation(a.ID, b.ID, -2)
+				g.adjustRelation(b.ID, a.ID, -2)
+			} else {
+				if a.Relations[b.ID] < 50 {
+					g.adjustRelation(a.ID, b.ID, 1)
+				}
+				if b.Relations[a.ID] < 50 {
+					g.adjustRelation(b.ID, a.ID, 1)
+				}
+			}
+		}
+	}
+}
+
+// inBorderContact reports whether any of a's units stand close to one of
+// b's cities.
+func (g *game) inBorderContact(a, b *player) bool {
+	for _, c := range b.Cities {
+		for _, u := range a.Units {
+			if g.distance(u.X, u.Y, c.X, c.Y) <= borderContactRadius {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *game) displayDiplomacy(player *player) {
+	fmt.Println("\n🤝 Foreign Relations:")
+	for _, other := range g.Players {
+		if other.ID == player.ID || other.Eliminated {
+			continue
+		}
+		t := player.Treaties[other.ID]
+		fmt.Printf("- %s: %s", other.Name, stanceToString(t.Stance))
+		if t.Stance == stanceCeasefire {
+			fmt.Printf(" (%d turns left)", t.TurnsLeft)
+		}
+		fmt.Printf(", they regard us at %d, we regard them at %d\n", other.Relations[player.ID], player.Relations[other.ID])
+	}
+}
+
+// voteMenu asks the player whom they back in this turn's world vote.
+func (g *game) voteMenu(player *player, validator *inputValidator) error {
+	candidateIDs := make([]int, 0, len(g.Players))
+	names := make([]string, 0, len(g.Players)+1)
+	for _, other := range g.Players {
+		switch {
+		case other.Eliminated:
+			continue
+		case other.ID == player.ID:
+			names = append(names, fmt.Sprintf("%s (yourself)", other.Name))
+		default:
+			names = append(names, fmt.Sprintf("%s (relation %d)", other.Name, player.Relations[other.ID]))
+		}
+		candidateIDs = append(candidateIDs, other.ID)
+	}
+	names = append(names, "Abstain")
+	
+	choice, err := validator.getChoiceInput("\n🗳️ The world votes! Whom do you support?", names)
+	if err != nil {
+		return err
+	}
+	if choice > len(candidateIDs) {
+		fmt.Println("🗳️ You abstained")
+		return nil
+	}
+	candidate := g.Players[candidateIDs[choice-1]]
+	if err := g.CastVote(player.ID, candidate.ID); err != nil {
+		return err
+	}
+	fmt.Printf("🗳️ You voted for %s\n", candidate.Name)
+	return nil
+}
+
+func (g *game) diplomacyMenu(player *player, validator *inputValidator) error {
+	g.displayDiplomacy(player)
+	
+	otherIDs := make([]int, 0, len(g.Players)-1)
+	names := make([]string, 0, len(g.Players)-1)
+	for _, other := range g.Players {
+		if other.ID != player.ID && !other.Eliminated {
+			otherIDs = append(otherIDs, other.ID)
+			names = append(names, fmt.Sprintf("%s (%s)", other.Name, stanceToString(g.stance(player.ID, other.ID))))
+		}
+	}
+	
+	if len(names) == 0 {
+		return fmt.Errorf("no other civilizations remain")
+	}
+	
+	choice, err := validator.getChoiceInput("\n🤝 Select Civilization:", names)
+	if err != nil {
+		return err
+	}
+	other := g.Players[otherIDs[choice-1]]
+	
+	action, err := validator.getChoiceInput(fmt.Sprintf("\n🤝 Dealing with %s:", other.Name), []string{
+		"Declare War",
+		"Propose Ceasefire",
+		"Propose Peace",
+		fmt.Sprintf("Send Gift (%d gold)", giftGold),
+		"Back",
+	})
+	if err != nil {
+		return err
+	}
+	
+	switch action {
+	case 1:
+		if err := g.DeclareWar(player.ID, other.ID); err != nil {
+			return err
+		}
+		fmt.Printf("⚔️ You declared war on %s!\n", other.Name)
+	case 2, 3:
+		proposal := proposeCeasefire
+		if action == 3 {
+			proposal = proposePeace
+		}
+		accepted, err := g.ProposeTreaty(player.ID, other.ID, proposal)
+		if err != nil {
+			return err
+		}
+		if accepted {
+			fmt.Printf("🤝 %s accepted. You are now at %s.\n", other.Name, stanceToString(g.stance(player.ID, other.ID)))
+		} else {
+			fmt.Printf("❌ %s rejected your proposal.\n", other.Name)
+		}
+	case 4:
+		if err := g.SendGift(player.ID, other.ID, giftGold); err != nil {
+			return err
+		}
+		fmt.Printf("🎁 %s appreciates your gift.\n", other.Name)
+	}
+	return nil
+}
+
+// ========== Territory ==========
+// Land belongs to cities, not to whoever last walked over it. Each city
+// claims the tiles within its border radius, which grows as the city
+// gathers culture. Where claims overlap, every city presses on the tile
+// with its culture, more strongly the closer it is, and the civilization
+// pressing hardest takes it; on a tie the tile stays where it was. A city's
+// own tile is always its owner's.
+const (
+	baseBorderRadius = 1
+	basePressure     = 10 // lets cities without culture hold their land
+)
+
+// borderGrowth is the culture at which a city's borders grow a ring.
+var borderGrowth = []int{10, 50}
+
+func (c *city) borderRadius() int {
+	radius := baseBorderRadius
+	for _, culture := range borderGrowth {
+		if c.Culture >= culture {
+			radius++
+		}
+	}
+	return radius
+}
+
+// culturePressure is the city's hold on a tile distance away inside its
+// borders.
+func (c *city) culturePressure(distance int) int {
+	return (c.Culture + basePressure) * (c.borderRadius() + 1 - distance)
+}
+
+// updateTerritory redraws every border from the cities' culture.
+func (g *game) updateTerritory() {
+	width := g.Settings.MapWidth
+	pressure := make([][]int, width*g.Settings.MapHeight)
+	for _, player := range g.Players {
+		for _, city := range player.Cities {
+			radius := city.borderRadius()
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					x, y := g.wrap(city.X+dx, city.Y+dy)
+					i := y*width + x
+					if pressure[i] == nil {
+						pressure[i] = make([]int, len(g.Players))
+					}
+					pressure[i][player.ID] += city.culturePressure(max(abs(dx), abs(dy)))
+				}
+			}
+		}
+	}
+	
+	for y, row := range g.Map {
+		for x := range row {
+			tile := &row[x]
+			claims := pressure[y*width+x]
+			if claims == nil {
+				tile.OwnerID = -1
+				continue
+			}
+			owner, strongest := -1, 0
+			if tile.OwnerID != -1 {
+				owner, strongest = tile.OwnerID, claims[tile.OwnerID]
+			}
+			for id, p := range claims {
+				if p > strongest {
+					owner, strongest = id, p
+				}
+			}
+			if strongest == 0 {
+				owner = -1
+			}
+			tile.OwnerID = owner
+		}
+	}
+	for _, player := range g.Players {
+		for _, city := range player.Cities {
+			g.Map[city.Y][city.X].OwnerID = player.ID
+		}
+	}
+}
+
+// territorySize counts the tiles inside the player's borders.
+func (g *game) territorySize(player *player) int {
+	size := 0
+	for _, row := range g.Map {
+		for _, tile := range row {
+			if tile.OwnerID == player.ID {
+				size++
+			}
+		}
+	}
+	return size
+}
+
+// ========== City Founding ==========
+func (g *game) foundCity(player *player, validator *inputValidator) error {
+	var settler *unit
+	for _, unit := range player.sortedUnits() {
+		if unit.Type == unitSettler {
+			settler = unit
+			break
+		}
+	}
+	
+	if settler == nil {
+		return fmt.Errorf("no settler unit available")
+	}
+	
+	fmt.Printf("Founding city at (%d,%d)\n", settler.X, settler.Y)
+	
+	cityName, err := validator.getStringInput("Enter city name: ", 3, 20)
+	if err != nil {
+		return err
+	}
+	
+	city, err := g.FoundCity(player.ID, settler.ID, cityName)
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("🏙️ Founded new city: %s!\n", city.Name)
+	return nil
+}
+
+// ========== City Conquest ==========
+// A military unit can march into an undefended city of a civilization it
+// is at war with and take it, buildings, production queue and all. The
+// city loses a citizen in the fighting. Until the end of the turn the
+// conqueror may raze it instead of keeping it. A civilization left with
+// neither cities nor settlers is eliminated.
+
+// foreignCity returns the city at (x, y) if it belongs to someone other
+// than playerID.
+func (g *game) foreignCity(playerID, x, y int) *city {
+	c, err := g.findCity(g.Map[y][x].CityID)
+	if err != nil || c.OwnerID == playerID {
+		return nil
+	}
+	return c
+}
+
+// canCapture reports why u couldn't take the city, or nil if it could.
+func (g *game) canCapture(u *unit, c *city) error {
+	if u.Type.isCivilian() {
+		return errCannotCapture
+	}
+	if g.stance(u.OwnerID, c.OwnerID) != stanceWar {
+		return fmt.Errorf("%w: %s", errNotAtWar, g.Players[c.OwnerID].Name)
+	}
+	if g.Map[c.Y][c.X].UnitID != -1 {
+		return errTileOccupied
+	}
+	return nil
+}
+
+// captureCity hands the city u has just marched into to u's owner.
+func (g *game) captureCity(u *unit, c *city) {
+	conqueror, loser := g.Players[u.OwnerID], g.Players[c.OwnerID]
+	c.Population = max(c.Population-1, 1)
+	c.Captured = true
+	u.Movement = 0
+	g.adjustRelation(loser.ID, conqueror.ID, -20)
+	g.logEvent(-1, "🏴 %s captured %s from %s!", conqueror.Name, c.Name, loser.Name)
+	
+	g.transferCity(c, conqueror)
+	g.updateVisibility(conqueror)
+	g.updateVisibility(loser)
+}
+
+// destroyCity removes the city from the map.
+func (g *game) destroyCity(c *city) {
+	owner := g.Players[c.OwnerID]
+	delete(owner.Cities, c.ID)
+	owner.CityCount--
+	g.Map[c.Y][c.X].CityID = -1
+	g.updateTerritory()
+	g.updateVisibility(owner)
+	g.checkElimination(owner)
+}
+
+// checkElimination knocks the player out of the game once they have
+// neither a city nor a settler to found one, disbanding what units they
+// have left.
+func (g *game) checkElimination(p *player) {
+	if p.Eliminated || p.CityCount > 0 {
+		return
+	}
+	for _, u := range p.Units {
+		if u.Type == unitSettler {
+			return
+		}
+	}
+	
+	for _, u := range p.sortedUnits() {
+		g.removeUnit(u)
+	}
+	p.Eliminated = true
+	g.logEvent(-1, "☠️ %s has been eliminated!", p.Name)
+}
+
+// aiCaptureTargets lists the cities the player knows of belonging to
+// civilizations it is at war with.
+func (g *game) aiCaptureTargets(p *player) []tilePos {
+	var targets []tilePos
+	for _, pos := range g.knownCities(p) {
+		owner := p.Memory[pos.Y][pos.X].CityOwnerID
+		if owner != p.ID && owner != -1 && g.stance(p.ID, owner) == stanceWar {
+			targets = append(targets, pos)
+		}
+	}
+	return targets
+}
+
+// ========== Building Effects ==========
+// buildingEffect describes what a building does for the city that owns it.
+// Bonuses are percentages; a city's effects are the sum over its buildings.
+type buildingEffect struct {
+	Culture         int // culture points per turn
+	Happiness       int // citizens made content
+	ResearchBonus   int
+	ProductionBonus int
+	DefenseBonus    int // for units defending in the city
+	Experience      int // starting experience of units built here
+	FoodKept        int // share of the food box kept when the city grows
+	Upkeep          int // gold per turn
+}
+
+var buildingEffects = [buildingCount]buildingEffect{
+	buildingMonument:   {Culture: 2, Happiness: 1, Upkeep: 1},
+	buildingGranary:    {FoodKept: 50, Upkeep: 1},
+	buildingLibrary:    {Culture: 1, ResearchBonus: 50, Upkeep: 1},
+	buildingTemple:     {Culture: 1, Happiness: 2, Upkeep: 1},
+	buildingBarracks:   {Experience: 10, Upkeep: 1},
+	buildingWalls:      {DefenseBonus: 100, Upkeep: 1},
+	buildingUniversity: {Culture: 1, ResearchBonus: 50, Upkeep: 3},
+	buildingFactory:    {ProductionBonus: 50, Upkeep: 3},
+}
+
+func (c *city) hasBuilding(b buildingType) bool {
+	for _, building := range c.Buildings {
+		if building == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *city) effects() buildingEffect {
+	var total buildingEffect
+	for _, b := range c.Buildings {
+		effect := buildingEffects[b]
+		total.Culture += effect.Culture
+		total.Happiness += effect.Happiness
+		total.ResearchBonus += effect.ResearchBonus
+		total.ProductionBonus += effect.ProductionBonus
+		total.DefenseBonus += effect.DefenseBonus
+		total.Experience += effect.Experience
+		total.FoodKept += effect.FoodKept
+		total.Upkeep += effect.Upkeep
+	}
+	return total
+}
+
+func (e buildingEffect) String() string {
+	var parts []string
+	if e.Culture > 0 {
+		parts = append(parts, fmt.Sprintf("+%d culture", e.Culture))
+	}
+	if e.Happiness > 0 {
+		parts = append(parts, fmt.Sprintf("+%d content", e.Happiness))
+	}
+	if e.ResearchBonus > 0 {
+		parts = append(parts, fmt.Sprintf("+%d%% research", e.ResearchBonus))
+	}
+	if e.ProductionBonus > 0 {
+		parts = append(parts, fmt.Sprintf("+%d%% production", e.ProductionBonus))
+	}
+	if e.DefenseBonus > 0 {
+		parts = append(parts, fmt.Sprintf("+%d%% defense", e.DefenseBonus))
+	}
+	if e.Experience > 0 {
+		parts = append(parts, fmt.Sprintf("+%d unit experience", e.Experience))
+	}
+	if e.FoodKept > 0 {
+		parts = append(parts, fmt.Sprintf("keeps %d%% food on growth", e.FoodKept))
+	}
+	if e.Upkeep > 0 {
+		parts = append(parts, fmt.Sprintf("%d gold upkeep", e.Upkeep))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ========== City Economy ==========
+// tileYield is what a worked tile produces each turn.
+type tileYield struct {
+	Food    int
+	Shields int
+	Trade   int
+}
+
+var terrainYields = [terrainCount]tileYield{
+	terrainOcean:     {Food: 1, Trade: 2},
+	terrainPlains:    {Food: 2, Shields: 1},
+	terrainDesert:    {Shields: 1},
+	terrainMountains: {Shields: 1},
+	terrainForest:    {Food: 1, Shields: 2},
+	terrainHills:     {Food: 1, Shields: 1},
+	terrainTundra:    {Food: 1},
+	terrainJungle:    {Food: 1},
+}
+
+func (y tileYield) add(other tileYield) tileYield {
+	return tileYield{Food: y.Food + other.Food, Shields: y.Shields + other.Shields, Trade: y.Trade + other.Trade}
+}
+
+func (g *game) tileYield(x, y int) tileYield {
+	tile := g.Map[y][x]
+	yield := terrainYields[tile.Terrain]
+	if tile.OwnerID != -1 {
+		yield = yield.add(resourceYields[tile.Resource])
+	}
+	switch tile.Improvement {
+	case improvementFarm:
+		yield.Food++
+	case improvementMine:
+		yield.Shields += 2
+	}
+	if tile.Route != routeNone && terrainMoveCost[tile.Terrain] == 1 {
+		yield.Trade++
+	}
+	if tile.Route == routeRailroad {
+		yield.Shields++
+	}
+	return yield
+}
+
+// cityCandidateTiles lists the tiles within cityRadius a city could work,
+// excluding its own center and tiles claimed by another civilization.
+func (g *game) cityCandidateTiles(city *city) []tilePos {
+	var tiles []tilePos
+	for dy := -cityRadius; dy <= cityRadius; dy++ {
+		for dx := -cityRadius; dx <= cityRadius; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			x, y := g.wrap(city.X+dx, city.Y+dy)
+			tile := g.Map[y][x]
+			if tile.CityID != -1 || (tile.OwnerID != -1 && tile.OwnerID != city.OwnerID) {
+				continue
+			}
+			tiles = append(tiles, tilePos{X: x, Y: y})
+		}
+	}
+	return tiles
+}
+
+// assignWorkedTiles puts every citizen of every city to work on the best
+// free tile around it, food first. Cities are served in ID order so a tile
+// shared by two cities goes to the older one.
+func (g *game) assignWorkedTiles() {
+	var cities []*city
+	for _, player := range g.Players {
+		cities = append(cities, player.sortedCities()...)
+	}
+	sort.Slice(cities, func(i, j int) bool { return cities[i].ID < cities[j].ID })
+	
+	taken := make(map[tilePos]bool)
+	for _, city := range cities {
+		candidates := g.cityCandidateTiles(city)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := g.tileYield(candidates[i].X, candidates[i].Y), g.tileYield(candidates[j].X, candidates[j].Y)
+			return a.Food*3+a.Shields*2+a.Trade > b.Food*3+b.Shields*2+b.Trade
+		})
+		
+		city.WorkedTiles = city.WorkedTiles[:0]
+		for _, pos := range candidates {
+			if len(city.WorkedTiles) >= city.Population {
+				break
+			}
+			if !taken[pos] {
+				taken[pos] = true
+				city.WorkedTiles = append(city.WorkedTiles, pos)
+			}
+		}
+	}
+}
+
+// cityYield totals the city center and its worked tiles.
+func (g *game) cityYield(city *city) tileYield {
+	total := g.tileYield(city.X, city.Y)
+	total.Food += cityCenterFoodBonus
+	for _, pos := range city.WorkedTiles {
+		total = total.add(g.tileYield(pos.X, pos.Y))
+	}
+	return total
+}
+
+func (g *game) foodSurplus(city *city) int {
+	return g.cityYield(city).Food - city.Population*foodPerCitizen
+}
+
+// foodBox is the food a city must store to grow past its current size.
+func foodBox(population int) int {
+	return foodBoxBase + population*foodBoxPerCitizen
+}
+
+// growCity stores the food surplus, growing the city when its food box is
+// full and shrinking it when the store runs dry. A Granary keeps part of
+// the box after growth.
+func (g *game) growCity(city *city, player *player) {
+	city.Food += g.foodSurplus(city)
+	
+	if box := foodBox(city.Population); city.Food >= box {
+		kept := box * min(city.effects().FoodKept, 100) / 100
+		city.Food = min(city.Food-box+kept, foodBox(city.Population+1)-1)
+		city.Population++
+		g.logEvent(player.ID, "🌾 %s grew to size %d", city.Name, city.Population)
+		return
+	}
+	
+	if city.Food < 0 {
+		city.Food = 0
+		if city.Population > 1 {
+			city.Population--
+			g.logEvent(player.ID, "💀 Famine in %s! The city shrank to size %d", city.Name, city.Population)
+		}
+	}
+}
+
+// cityProduction is the shields a city adds to its queue each turn: the
+// output of its worked tiles, multiplied by a Factory and cut by each
+// unhappy citizen.
+func (g *game) cityProduction(city *city) int {
+	shields := g.cityYield(city).Shields * (100 + city.effects().ProductionBonus) / 100
+	return shields * max(100-city.Unhappy*unhappyProductionPenalty, 0) / 100
+}
+
+// advanceProduction adds this turn's shields to the head of the queue.
+// Shields beyond the finished item's cost carry over to the next one. A
+// unit with nowhere to stand waits at the head of the queue.
+func (g *game) advanceProduction(city *city, player *player) error {
+	city.Production = g.cityProduction(city)
+	if len(city.ProductionQueue) == 0 {
+		return nil
+	}
+	
+	item := &city.ProductionQueue[0]
+	item.Progress += city.Production
+	if item.Progress < item.TotalCost {
+		return nil
+	}
+	
+	if err := g.completeProduction(item, city, player); err != nil {
+		if errors.Is(err, errNoUnitPlacement) {
+			// Only report the first turn the unit is stuck.
+			if item.Progress-city.Production < item.TotalCost {
+				g.logEvent(player.ID, "⏸️ %s cannot deploy its %s: %v", city.Name, item.Name, err)
+			}
+			item.Progress = item.TotalCost
+			return nil
+		}
+		return err
+	}
+	
+	overflow := item.Progress - item.TotalCost
+	city.ProductionQueue = city.ProductionQueue[1:]
+	if len(city.ProductionQueue) > 0 {
+		city.ProductionQueue[0].Progress += overflow
+	} else {
+		city.Overflow = overflow
+	}
+	return nil
+}
+
+// ========== Resources ==========
+// A resource only does anything inside its owner's territory. Food and
+// shields come with the tile when a city works it, Gold pays goldPerMine
+// a turn and contents a citizen in every city, and Iron and Horses let the
+// player build the units that need them wherever they lie in its lands.
+const goldPerMine = 3
+
+// resourceYields is what each resource adds to its tile's yield.
+var resourceYields = [resourceCount]tileYield{
+	resourceWheat: {Food: 2},
+	resourceFish:  {Food: 2},
+	resourceIron:  {Shields: 2},
+}
+
+// unitRequiredResource is the strategic resource a unit type needs.
+var unitRequiredResource = [unitCount]resourceType{
+	unitSwordsman: resourceIron,
+	unitKnight:    resourceHorses,
+}
+
+func isLuxury(resource resourceType) bool {
+	return resource == resourceGold
+}
+
+// workedResources counts the resources the player's cities work inside
+// its territory, city centers included.
+func (g *game) workedResources(player *player) [resourceCount]int {
+	var counts [resourceCount]int
+	for _, city := range player.Cities {
+		tiles := append([]tilePos{{X: city.X, Y: city.Y}}, city.WorkedTiles...)
+		for _, pos := range tiles {
+			if tile := g.Map[pos.Y][pos.X]; tile.OwnerID == player.ID {
+				counts[tile.Resource]++
+			}
+		}
+	}
+	return counts
+}
+
+// territoryResources counts the resources anywhere in the player's
+// territory, worked or not.
+func (g *game) territoryResources(player *player) [resourceCount]int {
+	var counts [resourceCount]int
+	for _, row := range g.Map {
+		for _, tile := range row {
+			if tile.OwnerID == player.ID {
+				counts[tile.Resource]++
+			}
+		}
+	}
+	return counts
+}
+
+// hasUnitResource reports whether the player holds the strategic resource
+// the unit type needs, if any.
+func (g *game) hasUnitResource(player *player, u unitType) bool {
+	required := unitRequiredResource[u]
+	return required == resourceNone || g.territoryResources(player)[required] > 0
+}
+
+// ========== Happiness ==========
+// luxuryCount is the number of luxury resources the player's cities work.
+// Each one contents a citizen in every city.
+func (g *game) luxuryCount(player *player) int {
+	count := 0
+	for res, n := range g.workedResources(player) {
+		if isLuxury(resourceType(res)) {
+			count += n
+		}
+	}
+	return count
+}
+
+// cityMood splits a city's citizens into content and unhappy ones. The
+// first contentCitizens are content, as are those won over by luxuries,
+// Temples and Monuments. A large empire and war weariness make further
+// citizens unhappy.
+func (g *game) cityMood(city *city, player *player) (content, unhappy int) {
+	content = contentCitizens + g.luxuryCount(player) + city.effects().Happiness
+	unhappy = max(city.Population-content, 0)
+	unhappy += max(player.CityCount-citiesBeforeUnhappiness, 0)
+	unhappy += player.WarWeariness / warWearinessPerUnhappy
+	unhappy = min(unhappy, city.Population)
+	return city.Population - unhappy, unhappy
+}
+
+// updateHappiness recomputes every city's mood. Cities where at least half
+// the citizens are unhappy are in disorder; every riotTurns of disorder
+// riots cost a citizen and half the production in progress, and after
+// revoltTurns the city defects to the nearest happier civilization.
+func (g *game) updateHappiness(player *player) {
+	player.WarWeariness = max(player.WarWeariness-1, 0)
+	
+	totalContent, totalCitizens := 0, 0
+	var target *city
+	for _, city := range player.sortedCities() {
+		content, unhappy := g.cityMood(city, player)
+		city.Unhappy = unhappy
+		totalContent += content
+		totalCitizens += city.Population
+		
+		if unhappy == 0 || unhappy*2 < city.Population {
+			city.UnrestTurns = 0
+			continue
+		}
+		
+		city.UnrestTurns++
+		target = nil
+		if city.UnrestTurns >= revoltTurns {
+			target = g.nearestHappierCity(city, player, revoltRadius)
+		}
+		switch {
+		case target != nil:
+			newOwner := g.Players[target.OwnerID]
+			g.logEvent(player.ID, "🔥 %s revolted and joined %s!", city.Name, newOwner.Name)
+			g.transferCity(city, newOwner)
+		case city.UnrestTurns%riotTurns == 0:
+			if len(city.ProductionQueue) > 0 {
+				city.ProductionQueue[0].Progress /= 2
+			}
+			city.Population = max(city.Population-1, 1)
+			g.logEvent(player.ID, "🔥 Riots in %s! Half its production is lost and the city shrank to size %d", city.Name, city.Population)
+		default:
+			g.logEvent(player.ID, "😠 %s is in civil disorder", city.Name)
+		}
+	}
+	
+	player.Happiness = startingHappiness
+	if totalCitizens > 0 {
+		player.Happiness = totalContent * 100 / totalCitizens
+	}
+}
+
+// nearestHappierCity finds the closest city within radius belonging to a
+// civilization whose citizens are happier than owner's.
+func (g *game) nearestHappierCity(from *city, owner *player, radius int) *city {
+	var nearest *city
+	best := radius + 1
+	for _, player := range g.Players {
+		if player.ID == owner.ID || player.Happiness <= owner.Happiness {
+			continue
+		}
+		for _, c := range player.sortedCities() {
+			if d := g.distance(from.X, from.Y, c.X, c.Y); d < best {
+				nearest, best = c, d
+			}
+		}
+	}
+	return nearest
+}
+
+// transferCity hands a city, with its buildings and production queue, to
+// a new owner. A garrison of the old owner standing in the city goes too.
+func (g *game) transferCity(c *city, newOwner *player) {
+	oldOwner := g.Players[c.OwnerID]
+	delete(oldOwner.Cities, c.ID)
+	oldOwner.CityCount--
+	
+	c.OwnerID = newOwner.ID
+	c.UnrestTurns = 0
+	newOwner.Cities[c.ID] = c
+	newOwner.CityCount++
+	
+	tile := &g.Map[c.Y][c.X]
+	tile.OwnerID = newOwner.ID
+	if garrison, exists := oldOwner.Units[tile.UnitID]; exists {
+		delete(oldOwner.Units, garrison.ID)
+		oldOwner.UnitCount--
+		garrison.OwnerID = newOwner.ID
+		newOwner.Units[garrison.ID] = garrison
+		newOwner.UnitCount++
+	}
+	g.updateTerritory()
+	g.checkElimination(oldOwner)
+}
+
+// ========== Gold Economy ==========
+// goldIncome is the gold a player's cities raise each turn from trade,
+// taxes on their citizens and the Gold they work.
+func (g *game) goldIncome(player *player) int {
+	income := g.workedResources(player)[resourceGold] * goldPerMine
+	for _, city := range player.Cities {
+		income += g.cityYield(city).Trade + city.Population*taxPerCitizen
+	}
+	return income
+}
+
+// goldUpkeep is what the player pays each turn for buildings and for
+// units beyond the free ones each city supports.
+func (g *game) goldUpkeep(player *player) int {
+	upkeep := 0
+	for _, city := range player.Cities {
+		upkeep += city.effects().Upkeep
+	}
+	upkeep += max(player.UnitCount-player.CityCount*freeUnitsPerCity, 0) * unitUpkeep
+	return upkeep
+}
+
+// collectGold settles the player's treasury for the turn. A player who
+// cannot pay goes bankrupt: buildings are sold, most expensive upkeep
+// first, and if that is not enough units are disbanded, newest first,
+// until the books balance. Whatever is still owed is carried as debt and
+// paid off from later turns' income.
+func (g *game) collectGold(player *player) {
+	player.Gold += g.goldIncome(player) - g.goldUpkeep(player)
+	player.Bankrupt = player.Gold < 0
+	if !player.Bankrupt {
+		return
+	}
+	g.logEvent(player.ID, "💸 %s is bankrupt!", player.Name)
+	
+	for player.Gold < 0 {
+		city, building := g.mostExpensiveBuilding(player)
+		if city == nil {
+			break
+		}
+		for i, b := range city.Buildings {
+			if b == building {
+				city.Buildings = append(city.Buildings[:i], city.Buildings[i+1:]...)
+				break
+			}
+		}
+		refund := g.getBuildingCost(building) * buildingSellPercent / 100
+		player.Gold += refund
+		g.logEvent(player.ID, "💸 %s sold its %s for %d gold", city.Name, buildingToString(building), refund)
+	}
+	
+	units := player.sortedUnits()
+	for i := len(units) - 1; i >= 0 && player.Gold < 0 && g.goldIncome(player) < g.goldUpkeep(player); i-- {
+		g.removeUnit(units[i])
+		g.logEvent(player.ID, "💸 %s disbanded a %s it could not pay for", player.Name, unitToString(units[i].Type))
+	}
+	// Disbanding the last settler of a civilization without cities ends it
+	g.checkElimination(player)
+	
+	player.Bankrupt = player.Gold < 0
+	if player.Bankrupt {
+		g.logEvent(player.ID, "💸 %s carries a debt of %d gold", player.Name, -player.Gold)
+	}
+}
+
+func (g *game) mostExpensiveBuilding(player *player) (*city, buildingType) {
+	var best *city
+	var bestBuilding buildingType
+	for _, city := range player.sortedCities() {
+		for _, b := range city.Buildings {
+			if best == nil || buildingEffects[b].Upkeep > buildingEffects[bestBuilding].Upkeep {
+				best, bestBuilding = city, b
+			}
+		}
+	}
+	return best, bestBuilding
+}
+
+// buyPrice is the gold needed to finish the item at the head of the queue.
+// The price rises faster than the shields still missing, so rushing an
+// item from scratch costs more than topping up a nearly finished one.
+func buyPrice(item productionItem) int {
+	remaining := max(item.TotalCost-item.Progress, 0)
+	return 2*remaining + remaining*remaining/20
+}
+
+// ========== Research System ==========
+type techInfo struct {
+	Cost    int // research points needed in a game of the default length
+	Prereqs []techType
+}
+
+var techTree = [techCount]techInfo{
+	techAgriculture:       {Cost: 0},
+	techPottery:           {Cost: 40, Prereqs: []techType{techAgriculture}},
+	techWriting:           {Cost: 60, Prereqs: []techType{techPottery}},
+	techMathematics:       {Cost: 80, Prereqs: []techType{techWriting}},
+	techConstruction:      {Cost: 80, Prereqs: []techType{techPottery}},
+	techPhilosophy:        {Cost: 120, Prereqs: []techType{techWriting, techMathematics}},
+	techEngineering:       {Cost: 140, Prereqs: []techType{techConstruction, techMathematics}},
+	techEducation:         {Cost: 160, Prereqs: []techType{techPhilosophy}},
+	techGunpowder:         {Cost: 200, Prereqs: []techType{techEngineering, techEducation}},
+	techIndustrialization: {Cost: 300, Prereqs: []techType{techGunpowder}},
+}
+
+// techCost is the research points the technology takes in a game with
+// these settings. Costs grow and shrink with the number of turns the game
+// lasts, so a short game still gets through the tree and a long one
+// doesn't run out of it early.
+func (s gameSettings) techCost(tech techType) int {
+	cost := techTree[tech].Cost
+	if cost == 0 {
+		return 0
+	}
+	return max(cost*s.turns()/defaultSettings().turns(), 1)
+}
+
+func (p *player) canResearch(tech techType) bool {
+	if !tech.isValid() || p.Techs[tech] {
+		return false
+	}
+	for _, prereq := range techTree[tech].Prereqs {
+		if !p.Techs[prereq] {
+			return false
+		}
+	}
+	return true
+}
+
+// researchOutput is the research points a player earns per turn: a base
+// amount per city plus points per citizen, raised by Libraries and
+// Universities.
+func (g *game) researchOutput(player *player) int {
+	total := 0
+	for _, city := range player.Cities {
+		points := researchPerCity + city.Population*researchPerPopulation
+		total += points * (100 + city.effects().ResearchBonus) / 100
+	}
+	return total
+}
+
+// advanceResearch adds this turn's research points and completes the
+// current technology once its cost is reached. Excess points carry over.
+func (g *game) advanceResearch(player *player) {
+	if player.Techs[player.Researching] {
+		return
+	}
+	
+	player.ResearchProgress += g.researchOutput(player)
+	cost := g.Settings.techCost(player.Researching)
+	if player.ResearchProgress < cost {
+		return
+	}
+	
+	player.ResearchProgress -= cost
+	player.Techs[player.Researching] = true
+	g.logEvent(player.ID, "🔬 %s researched %s!", player.Name, techToString(player.Researching))
+	player.Researching = g.chooseNextTech(player)
+}
+
+func (g *game) researchTech(player *player, validator *inputValidator) error {
+	techIDs, err := g.ResearchOptions(player.ID)
+	if err != nil {
+		return err
+	}
+	if len(techIDs) == 0 {
+		return fmt.Errorf("no technologies left to research")
+	}
+	
+	availableTechs := make([]string, 0, len(techIDs))
+	for _, tech := range techIDs {
+		availableTechs = append(availableTechs, fmt.Sprintf("%s (Cost: %d)", techToString(tech), g.Settings.techCost(tech)))
+	}
+	
+	choice, err := validator.getChoiceInput("\n🔬 Select Technology to Research:", availableTechs)
+	if err != nil {
+		return err
+	}
+	
+	if err := g.SetResearch(player.ID, techIDs[choice-1]); err != nil {
+		return err
+	}
+	fmt.Printf("Researching %s...\n", techToString(player.Researching))
+	return nil
+}
+
+// ========== Status Display ==========
+func (g *game) displayStatus(player *player) {
+	fmt.Printf("\n🏛️ %s Status (%s)\n", player.Name, formatYear(g.Year))
+	fmt.Printf("🏆 Score: %d\n", player.Score)
+	fmt.Printf("🎲 Seed: %d\n", g.Seed)
+	fmt.Printf("💰 Gold: %d (+%d income, -%d upkeep)\n", player.Gold, g.goldIncome(player), g.goldUpkeep(player))
+	if player.Bankrupt {
+		fmt.Println("💸 Bankrupt! Buildings and units are being sold off")
+	}
+	fmt.Printf("😊 Happiness: %d%% content", player.Happiness)
+	if player.WarWeariness > 0 {
+		fmt.Printf(" (war weariness %d)", player.WarWeariness)
+	}
+	fmt.Println()
+	var resources []string
+	for res, n := range g.territoryResources(player) {
+		if resourceType(res) != resourceNone && n > 0 {
+			resources = append(resources, fmt.Sprintf("%s x%d", resourceToString(resourceType(res)), n))
+		}
+	}
+	if len(resources) > 0 {
+		fmt.Printf("💎 Resources: %s\n", strings.Join(resources, ", "))
+	}
+	if player.Techs[player.Researching] {
+		fmt.Println("🔬 Researching: Nothing")
+	} else {
+		cost := g.Settings.techCost(player.Researching)
+		perTurn := g.researchOutput(player)
+		turns := "never"
+		if perTurn > 0 {
+			turns = fmt.Sprintf("%d turns", max(cost-player.ResearchProgress+perTurn-1, 0)/perTurn)
+		}
+		fmt.Printf("🔬 Researching: %s (%d/%d, +%d per turn, %s)\n",
+			techToString(player.Researching), player.ResearchProgress, cost, perTurn, turns)
+	}
+	
+	known := make([]string, 0, techCount)
+	for tech := techAgriculture; tech < techCount; tech++ {
+		if player.Techs[tech] {
+			known = append(known, techToString(tech))
+		}
+	}
+	fmt.Printf("📜 Technologies: %s\n", strings.Join(known, ", "))
+	g.displayVictoryProgress(player)
+	
+	fmt.Printf("\nCities (%d):\n", player.CityCount)
+	for _, city := range player.sortedCities() {
+		fmt.Printf("- %s (Pop: %d)\n", city.Name, city.Population)
+	}
+	
+	wounded := 0
+	for _, unit := range player.Units {
+		if unit.isWounded() {
+			wounded++
+		}
+	}
+	fmt.Printf("\nUnits (%d):\n", player.UnitCount)
+	if wounded > 0 {
+		fmt.Printf("🩹 %d wounded, pull them back to a city to heal\n", wounded)
+	}
+	for _, unit := range player.sortedUnits() {
+		fmt.Printf("- %s\n", unitSummary(unit))
+	}
+}
+
+// ========== Production System ==========
+// unitRequiredTech and buildingRequiredTech gate production. Agriculture is
+// known from the start, so items left at the zero value are always available.
+var (
+	unitRequiredTech = [unitCount]techType{
+		unitSwordsman: techMathematics,
+		unitKnight:    techConstruction,
+		unitMusketeer: techGunpowder,
+		unitCannon:    techEngineering,
+		unitTank:      techIndustrialization,
+	}
+	buildingRequiredTech = [buildingCount]techType{
+		buildingGranary:    techPottery,
+		buildingLibrary:    techWriting,
+		buildingTemple:     techPhilosophy,
+		buildingWalls:      techConstruction,
+		buildingUniversity: techEducation,
+		buildingFactory:    techIndustrialization,
+	}
+)
+
+func (p *player) canBuildUnit(u unitType) bool {
+	return u.isValid() && p.Techs[unitRequiredTech[u]]
+}
+
+func (p *player) canBuildBuilding(b buildingType) bool {
+	return b.isValid() && p.Techs[buildingRequiredTech[b]]
+}
+
+// availableUnits lists the unit types the player has the technology and
+// resources to build.
+func (g *game) availableUnits(p *player) []unitType {
+	var units []unitType
+	for u := unitSettler; u < unitCount; u++ {
+		if p.canBuildUnit(u) && g.hasUnitResource(p, u) {
+			units = append(units, u)
+		}
+	}
+	return units
+}
+
+func (p *player) availableBuildings() []buildingType {
+	var buildings []buildingType
+	for b := buildingMonument; b < buildingCount; b++ {
+		if p.canBuildBuilding(b) {
+			buildings = append(buildings, b)
+		}
+	}
+	return buildings
+}
+
+// projectCosts are the shields each space program stage takes.
+var projectCosts = [projectCount]int{
+	projectApolloProgram:    500,
+	projectSpaceshipHull:    800,
+	projectSpaceshipEngines: 1200,
+}
+
+// projectLocked reports why the player can't start the project yet, or nil
+// if they can. Every stage needs Industrialization and the stage before.
+func (p *player) projectLocked(project projectType) error {
+	switch {
+	case p.Projects[project]:
+		return fmt.Errorf("%w: %s is already complete", errBuildingExists, projectToString(project))
+	case !p.Techs[techIndustrialization]:
+		return fmt.Errorf("%w: %s requires %s", errItemLocked, projectToString(project), techToString(techIndustrialization))
+	case project > 0 && !p.Projects[project-1]:
+		return fmt.Errorf("%w: %s requires the %s", errItemLocked, projectToString(project), projectToString(project-1))
+	}
+	return nil
+}
+
+// nextProject is the space program stage the player can start next.
+func (p *player) nextProject() (projectType, bool) {
+	for project := projectApolloProgram; project < projectCount; project++ {
+		if !p.Projects[project] {
+			return project, p.projectLocked(project) == nil
+		}
+	}
+	return 0, false
+}
+
+func (g *game) produceUnit(player *player, city *city, validator *inputValidator) error {
+	options := make([]string, unitCount)
+	for i := 0; i < int(unitCount); i++ {
+		options[i] = unitToString(unitType(i))
+		if !player.canBuildUnit(unitType(i)) {
+			options[i] += fmt.Sprintf(" (requires %s)", techToString(unitRequiredTech[i]))
+		} else if !g.hasUnitResource(player, unitType(i)) {
+			options[i] += fmt.Sprintf(" (requires %s)", resourceToString(unitRequiredResource[i]))
+		}
+	}
+	
+	choice, err := validator.getChoiceInput("\n⚔️ Select Unit to Produce:", options)
+	if err != nil {
+		return err
+	}
+	
+	item, err := g.EnqueueProduction(player.ID, city.ID, productionUnit, choice-1)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s to production queue (Cost: %d)\n", item.Name, item.TotalCost)
+	return nil
+}
+
+func (g *game) buildBuilding(player *player, city *city, validator *inputValidator) error {
+	options := make([]string, buildingCount)
+	for i := 0; i < int(buildingCount); i++ {
+		options[i] = fmt.Sprintf("%s (%s)", buildingToString(buildingType(i)), buildingEffects[i])
+		if !player.canBuildBuilding(buildingType(i)) {
+			options[i] += fmt.Sprintf(" (requires %s)", techToString(buildingRequiredTech[i]))
+		}
+	}
+	
+	choice, err := validator.getChoiceInput("\n🏗️ Select Building to Construct:", options)
+	if err != nil {
+		return err
+	}
+	
+	item, err := g.EnqueueProduction(player.ID, city.ID, productionBuilding, choice-1)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s to production queue (Cost: %d)\n", item.Name, item.TotalCost)
+	return nil
+}
+
+func (g *game) buildProject(player *player, city *city, validator *inputValidator) error {
+	options := make([]string, projectCount)
+	for i := range options {
+		project := projectType(i)
+		options[i] = fmt.Sprintf("%s (Cost: %d)", projectToString(project), projectCosts[project])
+		if player.Projects[project] {
+			options[i] += " (complete)"
+		} else if err := player.projectLocked(project); err != nil {
+			options[i] += " (locked)"
+		}
+	}
+	
+	choice, err := validator.getChoiceInput("\n🚀 Select Space Program Project:", options)
+	if err != nil {
+		return err
+	}
+	
+	item, err := g.EnqueueProduction(player.ID, city.ID, productionProject, choice-1)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s to production queue (Cost: %d)\n", item.Name, item.TotalCost)
+	return nil
+}
+
+func (g *game) addToProductionQueue(city *city, itemType productionItemType, itemID int) (productionItem, error) {
+	if len(city.ProductionQueue) >= maxProductionQueue {
+		return productionItem{}, errProductionQueueFull
+	}
+	
+	var cost int
+	var name string
+	owner := g.Players[city.OwnerID]
+	
+	switch itemType {
+	case productionUnit:
+		unitType := unitType(itemID)
+		if !unitType.isValid() {
+			return productionItem{}, errInvalidUnit
+		}
+		if !owner.canBuildUnit(unitType) {
+			return productionItem{}, fmt.Errorf("%w: %s requires %s", errItemLocked, unitToString(unitType), techToString(unitRequiredTech[unitType]))
+		}
+		if !g.hasUnitResource(owner, unitType) {
+			return productionItem{}, fmt.Errorf("%w: %s requires %s", errMissingResource, unitToString(unitType), resourceToString(unitRequiredResource[unitType]))
+		}
+		cost = g.getUnitCost(unitType)
+		name = unitToString(unitType)
+	case productionBuilding:
+		buildingType := buildingType(itemID)
+		if !buildingType.isValid() {
+			return productionItem{}, errInvalidBuilding
+		}
+		if !owner.canBuildBuilding(buildingType) {
+			return productionItem{}, fmt.Errorf("%w: %s requires %s", errItemLocked, buildingToString(buildingType), techToString(buildingRequiredTech[buildingType]))
+		}
+		if city.hasBuilding(buildingType) {
+			return productionItem{}, errBuildingExists
+		}
+		for _, queued := range city.ProductionQueue {
+			if queued.Type == productionBuilding && queued.ItemID == itemID {
+				return productionItem{}, errBuildingExists
+			}
+		}
+		cost = g.getBuildingCost(buildingType)
+		name = buildingToString(buildingType)
+	case productionProject:
+		project := projectType(itemID)
+		if project < 0 || project >= projectCount {
+			return productionItem{}, errInvalidInput
+		}
+		if !g.Settings.Victories[victoryScience] {
+			return productionItem{}, fmt.Errorf("%w: the space program only leads to a science victory", errVictoryDisabled)
+		}
+		if err := owner.projectLocked(project); err != nil {
+			return productionItem{}, err
+		}
+		for _, c := range owner.Cities {
+			for _, queued := range c.ProductionQueue {
+				if queued.Type == productionProject && queued.ItemID == itemID {
+					return productionItem{}, fmt.Errorf("%w: %s is already building it", errBuildingExists, c.Name)
+				}
+			}
+		}
+		cost = projectCosts[project]
+		name = projectToString(project)
+	default:
+		return productionItem{}, errInvalidInput
+	}
+	
+	item := productionItem{
+		Type:      itemType,
+		ItemID:    itemID,
+		Progress:  0,
+		TotalCost: cost,
+		Name:      name,
+	}
+	if len(city.ProductionQueue) == 0 {
+		item.Progress, city.Overflow = city.Overflow, 0
+	}
+	city.ProductionQueue = append(city.ProductionQueue, item)
+	return item, nil
+}
+
+func (g *game) getUnitCost(ut unitType) int {
+	costs := map[unitType]int{
+		unitSettler:  100,
+		unitWarrior:   50,
+		unitArcher:    60,
+		unitSwordsman: 80,
+		unitKnight:   120,
+		unitMusketeer:150,
+		unitCannon:   200,
+		unitTank:     300,
+		unitWorker:    60,
+	}
+	return costs[ut]
+}
+
+func (g *game) getBuildingCost(bt buildingType) int {
+	costs := map[buildingType]int{
+		buildingMonument:  80,
+		buildingGranary:  100,
+		buildingLibrary:  120,
+		buildingTemple:   150,
+		buildingBarracks:100,
+		buildingWalls:   200,
+		buildingUniversity:250,
+		buildingFactory: 300,
+	}
+	return costs[bt]
+}
+
+// ========== Engine API ==========
+// The methods in this section are how frontends (the terminal menus, the
+// AI and any other bot) change game state. They check turn order and
+// ownership, never read input or print, and report outcomes through typed
+// results, gameErrors and the event log.
+
+type gameEvent struct {
+	PlayerID int // -1 for events that concern everyone
+	Message  string
+}
+
+type moveResult struct {
+	UnitID       int
+	FromX, FromY int
+	X, Y         int
+	Combat       *combatResult // set when the move was an attack
+	CapturedCity *city         // set when the move took an enemy city
+}
+
+type turnResult struct {
+	NextPlayerID int
+	Year         int
+	YearEnded    bool
+	GameOver     bool
+	WinnerID     int
+	Victory      victoryType
+	Events       []gameEvent
+}
+
+func (g *game) logEvent(playerID int, format string, args ...any) {
+	g.events = append(g.events, gameEvent{PlayerID: playerID, Message: fmt.Sprintf(format, args...)})
+}
+
+// drainEvents returns the events logged since the previous call.
+func (g *game) drainEvents() []gameEvent {
+	events := g.events
+	g.events = nil
+	return events
+}
+
+// Player returns the acting player's own state. Rivals are only seen
+// through VisibleTile, as the player sees them.
+func (g *game) Player(playerID int) (*player, error) {
+	player, err := g.findPlayer(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if g.CurrentPlayerIndex != playerID {
+		return nil, errNotYourTurn
+	}
+	return player, nil
+}
+
+// City returns one of the player's own cities.
+func (g *game) City(playerID, cityID int) (*city, error) {
+	player, err := g.Player(playerID)
+	if err != nil {
+		return nil, err
+	}
+	city, exists := player.Cities[cityID]
+	if !exists {
+		return nil, errCityNotFound
+	}
+	return city, nil
+}
+
+// Unit returns one of the player's own units.
+func (g *game) Unit(playerID, unitID int) (*unit, error) {
+	player, err := g.Player(playerID)
+	if err != nil {
+		return nil, err
+	}
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return nil, errUnitNotFound
+	}
+	return unit, nil
+}
+
+func (g *game) findPlayer(playerID int) (*player, error) {
+	if playerID < 0 || playerID >= len(g.Players) {
+		return nil, errPlayerNotFound
+	}
+	return g.Players[playerID], nil
+}
+
+func (g *game) findCity(cityID int) (*city, error) {
+	for _, player := range g.Players {
+		if city, exists := player.Cities[cityID]; exists {
+			return city, nil
+		}
+	}
+	return nil, errCityNotFound
+}
+
+func (g *game) findUnit(unitID int) (*unit, error) {
+	for _, player := range g.Players {
+		if unit, exists := player.Units[unitID]; exists {
+			return unit, nil
+		}
+	}
+	return nil, errUnitNotFound
+}
+
+// actingPlayer returns the player if the game is running and it is their turn.
+func (g *game) actingPlayer(playerID int) (*player, error) {
+	player, err := g.findPlayer(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if !g.Running {
+		return nil, errGameOver
+	}
+	if g.CurrentPlayerIndex != playerID {
+		return nil, errNotYourTurn
+	}
+	return player, nil
+}
+
+func (g *game) MoveUnit(playerID, unitID, x, y int) (moveResult, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return moveResult{}, err
+	}
+	
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return moveResult{}, errUnitNotFound
+	}
+	if x < 0 || x >= g.Settings.MapWidth || y < 0 || y >= g.Settings.MapHeight {
+		return moveResult{}, errOutOfBounds
+	}
+	
+	result := moveResult{UnitID: unit.ID, FromX: unit.X, FromY: unit.Y}
+	
+	// Moving onto an enemy unit is an attack.
+	if target := g.Map[y][x].UnitID; target != -1 {
+		defender, err := g.findUnit(target)
+		if err != nil {
+			return moveResult{}, err
+		}
+		if defender.OwnerID != player.ID {
+			if g.stance(player.ID, defender.OwnerID) != stanceWar {
+				return moveResult{}, fmt.Errorf("%w: %s", errNotAtWar, g.Players[defender.OwnerID].Name)
+			}
+			combat, err := g.attack(unit, defender)
+			if err != nil {
+				return moveResult{}, err
+			}
+			unit.setOrder(orderNone)
+			result.X, result.Y = unit.X, unit.Y
+			result.Combat = &combat
+			g.updateVisibility(player)
+			return result, nil
+		}
+	}
+	
+	target := g.foreignCity(player.ID, x, y)
+	if err := g.moveUnit(unit, x, y); err != nil {
+		return moveResult{}, err
+	}
+	unit.setOrder(orderNone)
+	result.X, result.Y = unit.X, unit.Y
+	result.CapturedCity = target
+	g.updateVisibility(player)
+	return result, nil
+}
+
+// GoTo gives the unit a standing order to walk to (x, y). It sets off at
+// once and carries on at the start of each of its owner's turns until it
+// arrives or finds no way through.
+func (g *game) GoTo(playerID, unitID, x, y int) (moveResult, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return moveResult{}, err
+	}
+	
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return moveResult{}, errUnitNotFound
+	}
+	if x < 0 || x >= g.Settings.MapWidth || y < 0 || y >= g.Settings.MapHeight {
+		return moveResult{}, errOutOfBounds
+	}
+	if _, err := g.findPath(player, unit.X, unit.Y, x, y); err != nil {
+		return moveResult{}, err
+	}
+	
+	result := moveResult{UnitID: unit.ID, FromX: unit.X, FromY: unit.Y}
+	unit.setOrder(orderGoTo)
+	unit.Destination = &tilePos{X: x, Y: y}
+	g.followGoTo(unit)
+	result.X, result.Y = unit.X, unit.Y
+	return result, nil
+}
+
+// SetOrder gives the unit a standing order. Exploring and automated units
+// set off at once. Go-to orders need a destination and are given with GoTo.
+func (g *game) SetOrder(playerID, unitID int, order unitOrder) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return errUnitNotFound
+	}
+	switch {
+	case order < 0 || order >= orderCount:
+		return errInvalidOrder
+	case order == orderGoTo:
+		return fmt.Errorf("%w: go-to orders are given with a destination", errInvalidOrder)
+	case order == orderBuild:
+		return fmt.Errorf("%w: workers are set to build with BuildImprovement", errInvalidOrder)
+	case order == orderFortify && unit.Type.isCivilian():
+		return fmt.Errorf("%w: %ss can't fortify", errInvalidOrder, unitToString(unit.Type))
+	case order == orderFortify && unit.Order == orderFortify:
+		return fmt.Errorf("%w: the unit is already fortified", errInvalidOrder)
+	case order == orderFortify && unit.Movement <= 0:
+		return fmt.Errorf("%w: it takes a move to dig in", errNoMovesLeft)
+	}
+	
+	unit.setOrder(order)
+	if order == orderExplore || order == orderAutomate {
+		g.carryOutOrder(player, unit)
+	}
+	return nil
+}
+
+// BuildImprovement sets the worker to work on the tile it stands on. Work
+// starts at once and carries on at the start of each of its owner's turns
+// until the job is done.
+func (g *game) BuildImprovement(playerID, unitID int, job workerJob) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return errUnitNotFound
+	}
+	if unit.Type != unitWorker {
+		return errNotWorker
+	}
+	if err := g.canDoJob(player, unit.X, unit.Y, job); err != nil {
+		return err
+	}
+	
+	unit.setOrder(orderBuild)
+	unit.Job = job
+	g.carryOutOrder(player, unit)
+	return nil
+}
+
+// Promote gives the unit a promotion it has earned.
+func (g *game) Promote(playerID, unitID int, promotion promotionType) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	
+	unit, exists := player.Units[unitID]
+	if !exists {
+		return errUnitNotFound
+	}
+	if promotion < 0 || promotion >= promotionCount {
+		return fmt.Errorf("%w: unknown promotion %d", errInvalidInput, promotion)
 	}
-	
-	return g.moveUnit(unit, newX, newY)
-}
-
-func (g *game) moveUnit(unit *unit, newX, newY int) error {
-	if !g.isValidTile(newX, newY) {
-		return errInvalidMove
+	if !unit.canPromote() {
+		return errNoPromotion
 	}
-	
-	if g.Map[newY][newX].UnitID != -1 {
-		return fmt.Errorf("tile occupied by another unit")
+	if unit.hasPromotion(promotion) {
+		return errPromotionKnown
 	}
 	
-	// Clear old position
-	g.Map[unit.Y][unit.X].UnitID = -1
-	
-	// Set new position
-	unit.X, unit.Y = newX, newY
-	g.Map[newY][newX].UnitID = unit.ID
-	g.Map[newY][newX].OwnerID = unit.OwnerID
-	
-	unit.Movement = 0
+	unit.promote(promotion)
 	return nil
 }
 
-// ========== City Founding ==========
-func (g *game) foundCity(player *player, validator *inputValidator) error {
-	var settler *unit
-	for _, unit := range player.Units {
-		if unit.Type == unitSettler {
-			settler = unit
-			break
+// IdleUnits returns the current player's units that still have moves and
+// no orders, in ID order.
+func (g *game) IdleUnits(playerID int) ([]*unit, error) {
+	player, err := g.Player(playerID)
+	if err != nil {
+		return nil, err
+	}
+	var idle []*unit
+	for _, unit := range player.sortedUnits() {
+		if unit.isIdle() {
+			idle = append(idle, unit)
 		}
 	}
-	
-	if settler == nil {
-		return fmt.Errorf("no settler unit available")
+	return idle, nil
+}
+
+// FoundCity consumes the settler and founds a city on its tile.
+func (g *game) FoundCity(playerID, unitID int, name string) (*city, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return nil, err
 	}
 	
-	fmt.Printf("Founding city at (%d,%d)\n", settler.X, settler.Y)
-	
-	cityName, err := validator.getStringInput("Enter city name: ", 3, 20)
-	if err != nil {
-		return err
+	settler, exists := player.Units[unitID]
+	if !exists {
+		return nil, errUnitNotFound
+	}
+	if settler.Type != unitSettler {
+		return nil, errNotSettler
+	}
+	if len(name) < 3 || len(name) > 20 {
+		return nil, errInvalidName
+	}
+	if g.Map[settler.Y][settler.X].CityID != -1 {
+		return nil, errCityExists
+	}
+	if owner := g.Map[settler.Y][settler.X].OwnerID; owner != -1 && owner != player.ID {
+		return nil, fmt.Errorf("%w: the land belongs to %s", errForeignTerritory, g.Players[owner].Name)
 	}
 	
 	city := &city{
 		ID:         g.NextCityID,
-		Name:       cityName,
+		Name:       name,
 		Population: baseCityPopulation,
 		OwnerID:    player.ID,
 		X:          settler.X,
@@ -768,164 +4613,291 @@ func (g *game) foundCity(player *player, validator *inputValidator) error {
 	
 	g.Map[settler.Y][settler.X].CityID = city.ID
 	g.Map[settler.Y][settler.X].UnitID = -1
+	g.Map[settler.Y][settler.X].OwnerID = player.ID
 	
 	player.Cities[city.ID] = city
 	player.CityCount++
 	delete(player.Units, settler.ID)
 	player.UnitCount--
+	g.updateTerritory()
+	g.updateVisibility(player)
+	
+	return city, nil
+}
+
+// RazeCity burns a city the player captured this turn to the ground.
+func (g *game) RazeCity(playerID, cityID int) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
+	}
 	
-	fmt.Printf("🏙️ Founded new city: %s!\n", cityName)
+	city, exists := player.Cities[cityID]
+	if !exists {
+		return errCityNotFound
+	}
+	if !city.Captured {
+		return errCannotRaze
+	}
+	
+	g.logEvent(-1, "🔥 %s razed %s", player.Name, city.Name)
+	g.destroyCity(city)
 	return nil
 }
 
-// ========== Research System ==========
-func (g *game) researchTech(player *player, validator *inputValidator) error {
-	availableTechs := make([]string, 0, techCount)
-	techIDs := make([]techType, 0, techCount)
+func (g *game) EnqueueProduction(playerID, cityID int, itemType productionItemType, itemID int) (productionItem, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return productionItem{}, err
+	}
 	
-	for tech := techAgriculture; tech < techCount; tech++ {
-		if !player.Techs[tech] {
-			availableTechs = append(availableTechs, techToString(tech))
-			techIDs = append(techIDs, tech)
-		}
+	city, exists := player.Cities[cityID]
+	if !exists {
+		return productionItem{}, errCityNotFound
+	}
+	return g.addToProductionQueue(city, itemType, itemID)
+}
+
+// BuyProduction pays gold to finish the item at the head of the city's
+// queue; it is delivered at the end of the year.
+func (g *game) BuyProduction(playerID, cityID int) (int, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return 0, err
 	}
 	
-	if len(availableTechs) == 0 {
-		return fmt.Errorf("no technologies left to research")
+	city, exists := player.Cities[cityID]
+	if !exists {
+		return 0, errCityNotFound
+	}
+	if len(city.ProductionQueue) == 0 || city.ProductionQueue[0].Progress >= city.ProductionQueue[0].TotalCost {
+		return 0, errNothingToBuy
 	}
 	
-	choice, err := validator.getChoiceInput("\n🔬 Select Technology to Research:", availableTechs)
+	price := buyPrice(city.ProductionQueue[0])
+	if player.Gold < price {
+		return 0, fmt.Errorf("%w: %d needed, %d available", errNotEnoughGold, price, player.Gold)
+	}
+	
+	player.Gold -= price
+	city.ProductionQueue[0].Progress = city.ProductionQueue[0].TotalCost
+	return price, nil
+}
+
+// DeclareWar ends any peace or ceasefire with the target. Breaking a
+// treaty angers the victim and, to a lesser degree, every other witness.
+func (g *game) DeclareWar(playerID, targetID int) error {
+	player, err := g.actingPlayer(playerID)
 	if err != nil {
 		return err
 	}
+	target, err := g.findPlayer(targetID)
+	if err != nil || targetID == playerID {
+		return errPlayerNotFound
+	}
+	if target.Eliminated {
+		return errPlayerEliminated
+	}
 	
-	player.Researching = techIDs[choice-1]
-	fmt.Printf("Researching %s...\n", techToString(player.Researching))
+	previous := g.stance(playerID, targetID)
+	if previous == stanceWar {
+		return errAlreadyAtWar
+	}
+	
+	g.setStance(playerID, targetID, stanceWar)
+	g.adjustRelation(targetID, playerID, -50)
+	for _, witness := range g.Players {
+		if witness.ID != playerID && witness.ID != targetID {
+			g.adjustRelation(witness.ID, playerID, -10)
+		}
+	}
+	g.logEvent(-1, "⚔️ %s declared war on %s, breaking their %s!", player.Name, g.Players[targetID].Name, stanceToString(previous))
 	return nil
 }
 
-// ========== Status Display ==========
-func (g *game) displayStatus(player *player) {
-	fmt.Printf("\n🏛️ %s Status (%d BC)\n", player.Name, g.Year)
-	fmt.Printf("🏆 Score: %d\n", player.Score)
-	fmt.Printf("💰 Gold: %d\n", player.Gold)
-	fmt.Printf("😊 Happiness: %d\n", player.Happiness)
-	fmt.Printf("🔬 Researching: %s\n", techToString(player.Researching))
+// ProposeTreaty offers a ceasefire (from war) or peace (from war or
+// ceasefire). The target decides from its relation to the proposer.
+func (g *game) ProposeTreaty(playerID, targetID int, proposal proposalType) (bool, error) {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return false, err
+	}
+	target, err := g.findPlayer(targetID)
+	if err != nil || targetID == playerID {
+		return false, errPlayerNotFound
+	}
+	if target.Eliminated {
+		return false, errPlayerEliminated
+	}
 	
-	fmt.Printf("\nCities (%d):\n", player.CityCount)
-	for _, city := range player.Cities {
-		fmt.Printf("- %s (Pop: %d)\n", city.Name, city.Population)
+	current := g.stance(playerID, targetID)
+	switch {
+	case proposal == proposeCeasefire && current != stanceWar,
+		proposal == proposePeace && current == stancePeace:
+		return false, errInvalidProposal
 	}
 	
-	fmt.Printf("\nUnits (%d):\n", player.UnitCount)
-	for _, unit := range player.Units {
-		fmt.Printf("- %s at (%d,%d)\n", unitToString(unit.Type), unit.X, unit.Y)
+	if !g.acceptsProposal(target, playerID, proposal) {
+		g.adjustRelation(playerID, targetID, -5)
+		return false, nil
 	}
+	
+	if proposal == proposeCeasefire {
+		g.setStance(playerID, targetID, stanceCeasefire)
+	} else {
+		g.setStance(playerID, targetID, stancePeace)
+	}
+	g.adjustRelation(targetID, playerID, 10)
+	g.logEvent(-1, "🤝 %s and %s agreed to a %s", player.Name, target.Name, stanceToString(g.stance(playerID, targetID)))
+	return true, nil
 }
 
-// ========== Production System ==========
-func (g *game) produceUnit(city *city, validator *inputValidator) error {
-	options := make([]string, unitCount)
-	for i := 0; i < int(unitCount); i++ {
-		options[i] = unitToString(unitType(i))
-	}
-	
-	choice, err := validator.getChoiceInput("\n⚔️ Select Unit to Produce:", options)
+func (g *game) SendGift(playerID, targetID, gold int) error {
+	player, err := g.actingPlayer(playerID)
 	if err != nil {
 		return err
 	}
-	
-	unitType := unitType(choice - 1)
-	if !unitType.isValid() {
-		return errInvalidUnit
+	target, err := g.findPlayer(targetID)
+	if err != nil || targetID == playerID {
+		return errPlayerNotFound
+	}
+	if target.Eliminated {
+		return errPlayerEliminated
+	}
+	if gold <= 0 {
+		return errInvalidInput
+	}
+	if player.Gold < gold {
+		return fmt.Errorf("%w: %d needed, %d available", errNotEnoughGold, gold, player.Gold)
 	}
 	
-	return g.addToProductionQueue(city, productionUnit, int(unitType))
+	player.Gold -= gold
+	target.Gold += gold
+	g.adjustRelation(targetID, playerID, gold/5)
+	return nil
 }
 
-func (g *game) buildBuilding(city *city, validator *inputValidator) error {
-	options := make([]string, buildingCount)
-	for i := 0; i < int(buildingCount); i++ {
-		options[i] = buildingToString(buildingType(i))
+// CastVote records the player's ballot in this turn's world vote. It can
+// be changed until the player's turn ends; a civilization that doesn't
+// vote abstains.
+func (g *game) CastVote(playerID, candidateID int) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
 	}
-	
-	choice, err := validator.getChoiceInput("\n🏗️ Select Building to Construct:", options)
+	if !g.voteOpen() {
+		return errNoVote
+	}
+	candidate, err := g.findPlayer(candidateID)
 	if err != nil {
 		return err
 	}
-	
-	buildingType := buildingType(choice - 1)
-	if !buildingType.isValid() {
-		return errInvalidUnit
+	if candidate.Eliminated {
+		return errPlayerEliminated
 	}
 	
-	return g.addToProductionQueue(city, productionBuilding, int(buildingType))
+	g.Ballots[player.ID] = candidate.ID
+	return nil
 }
 
-func (g *game) addToProductionQueue(city *city, itemType productionItemType, itemID int) error {
-	if len(city.ProductionQueue) >= maxProductionQueue {
-		return errProductionQueueFull
+// ResearchOptions lists the technologies the current player could
+// research next.
+func (g *game) ResearchOptions(playerID int) ([]techType, error) {
+	player, err := g.Player(playerID)
+	if err != nil {
+		return nil, err
 	}
 	
-	var cost int
-	var name string
-	
-	switch itemType {
-	case productionUnit:
-		unitType := unitType(itemID)
-		cost = g.getUnitCost(unitType)
-		name = unitToString(unitType)
-	case productionBuilding:
-		buildingType := buildingType(itemID)
-		cost = g.getBuildingCost(buildingType)
-		name = buildingToString(buildingType)
+	var techs []techType
+	for tech := techAgriculture; tech < techCount; tech++ {
+		if player.canResearch(tech) {
+			techs = append(techs, tech)
+		}
 	}
-	
-	city.ProductionQueue = append(city.ProductionQueue, productionItem{
-		Type:      itemType,
-		ItemID:    itemID,
-		Progress:  0,
-		TotalCost: cost,
-		Name:      name,
-	})
-	
-	fmt.Printf("Added %s to production queue (Cost: %d)\n", name, cost)
-	return nil
+	return techs, nil
 }
 
-func (g *game) getUnitCost(unitType unitType) int {
-	costs := map[unitType]int{
-		unitSettler:  100,
-		unitWarrior:   50,
-		unitArcher:    60,
-		unitSwordsman: 80,
-		unitKnight:   120,
-		unitMusketeer:150,
-		unitCannon:   200,
-		unitTank:     300,
+func (g *game) SetResearch(playerID int, tech techType) error {
+	player, err := g.actingPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	if !tech.isValid() {
+		return errInvalidTech
+	}
+	if player.Techs[tech] {
+		return errTechKnown
+	}
+	if !player.canResearch(tech) {
+		return errTechLocked
 	}
-	return costs[unitType]
+	
+	player.Researching = tech
+	return nil
 }
 
-func (g *game) getBuildingCost(buildingType buildingType) int {
-	costs := map[buildingType]int{
-		buildingMonument:  80,
-		buildingGranary:  100,
-		buildingLibrary:  120,
-		buildingTemple:   150,
-		buildingBarracks:100,
-		buildingWalls:   200,
-		buildingUniversity:250,
-		buildingFactory: 300,
+// EndTurn hands control to the next player, running the end-of-year update
+// once every player has moved.
+func (g *game) EndTurn(playerID int) (turnResult, error) {
+	if _, err := g.actingPlayer(playerID); err != nil {
+		return turnResult{Events: g.drainEvents()}, err
+	}
+	
+	// Captured cities are kept once the conqueror's turn is over
+	for _, city := range g.Players[playerID].Cities {
+		city.Captured = false
+	}
+	
+	var result turnResult
+	found := false
+	for range g.Players {
+		g.CurrentPlayerIndex = (g.CurrentPlayerIndex + 1) % len(g.Players)
+		if g.CurrentPlayerIndex == 0 {
+			result.YearEnded = true
+			if err := g.endYear(); err != nil {
+				result.Events = g.drainEvents()
+				return result, err
+			}
+		}
+		// Eliminated civilizations are passed over
+		if !g.Players[g.CurrentPlayerIndex].Eliminated {
+			found = true
+			break
+		}
+	}
+	if !found {
+		// Every civilization has fallen, so the game ends without a winner
+		g.Running = false
+		g.WinnerID = -1
+		result.Year = g.Year
+		result.GameOver, result.WinnerID = true, -1
+		result.Events = g.drainEvents()
+		return result, nil
+	}
+	
+	// Whatever moved since, the next player starts their turn seeing
+	// from where their cities and units are now, and then their standing
+	// orders are carried out.
+	next := g.Players[g.CurrentPlayerIndex]
+	g.updateVisibility(next)
+	g.carryOutOrders(next)
+	result.NextPlayerID = g.CurrentPlayerIndex
+	result.Year = g.Year
+	result.WinnerID = -1
+	if err := g.checkGameOver(); err != nil {
+		g.Running = false
+		result.GameOver = true
+		result.WinnerID = g.WinnerID
+		result.Victory = g.Victory
 	}
-	return costs[buildingType]
+	result.Events = g.drainEvents()
+	return result, nil
 }
 
 // ========== Main Game Loop ==========
-func (g *game) Run() {
-	scanner := bufio.NewScanner(os.Stdin)
-	validator := newInputValidator(scanner)
-	
+// Run plays the game in the terminal, reading the human players' input
+// through validator.
+func (g *game) Run(validator *inputValidator) {
 	fmt.Println("🏛️ Welcome to Civilization!")
 	fmt.Println("Lead your civilization from ancient times to the modern era")
 	
@@ -936,6 +4908,8 @@ func (g *game) Run() {
 		}
 	}()
 	
+	// Each human's own events wait here until they are at the keyboard
+	inbox := make(map[int][]gameEvent)
 	for g.Running {
 		if err := g.checkGameOver(); err != nil {
 			g.displayWinner()
@@ -943,67 +4917,119 @@ func (g *game) Run() {
 		}
 		
 		currentPlayer := g.Players[g.CurrentPlayerIndex]
-		fmt.Printf("\n======= %s's Turn (%d BC) =======\n", currentPlayer.Name, g.Year)
+		fmt.Printf("\n======= %s's Turn (%s) =======\n", currentPlayer.Name, formatYear(g.Year))
+		printEvents(inbox[currentPlayer.ID])
+		delete(inbox, currentPlayer.ID)
 		
 		if currentPlayer.IsAI {
+			fmt.Printf("%s (AI) is thinking...\n", currentPlayer.Name)
 			if err := g.aiTurn(currentPlayer); err != nil {
 				fmt.Printf("⚠️ AI turn error: %v\n", err)
 			}
 		} else {
+			if _, voted := g.Ballots[currentPlayer.ID]; g.voteOpen() && !voted {
+				if err := g.voteMenu(currentPlayer, validator); err != nil {
+					fmt.Printf("Vote error: %v\n", err)
+				}
+			}
 			if err := g.playerTurn(currentPlayer, validator); err != nil {
+				if errors.Is(err, errGameLoaded) {
+					// Resume the loaded game at its own current player.
+					clear(inbox)
+					continue
+				}
+				if errors.Is(err, errInputClosed) {
+					fmt.Println("\n⚠️ Input closed; leaving the game")
+					return
+				}
 				fmt.Printf("⚠️ Player turn error: %v\n", err)
 			}
 		}
 		
-		g.CurrentPlayerIndex = (g.CurrentPlayerIndex + 1) % len(g.Players)
-		if g.CurrentPlayerIndex == 0 {
-			if err := g.endYear(); err != nil {
-				fmt.Printf("⚠️ Year end error: %v\n", err)
-			}
+		viewerID := -1
+		if !currentPlayer.IsAI {
+			viewerID = currentPlayer.ID
+		}
+		result, err := g.EndTurn(currentPlayer.ID)
+		printEvents(g.sortEvents(result.Events, viewerID, inbox))
+		if err != nil {
+			fmt.Printf("⚠️ Turn end error: %v\n", err)
+		}
+		if result.GameOver {
+			g.displayWinner()
+		}
+	}
+}
+
+func printEvents(events []gameEvent) {
+	for _, event := range events {
+		fmt.Println(event.Message)
+	}
+}
+
+// sortEvents picks out the events to show now: those that concern
+// everyone and those of viewerID, the human at the keyboard, or -1 if
+// none is. Other humans' events are held in inbox for their next turn, and
+// AI players' are dropped, so no one reads about a rival's moves.
+func (g *game) sortEvents(events []gameEvent, viewerID int, inbox map[int][]gameEvent) []gameEvent {
+	var shown []gameEvent
+	for _, event := range events {
+		switch {
+		case event.PlayerID == -1 || event.PlayerID == viewerID:
+			shown = append(shown, event)
+		case !g.Players[event.PlayerID].IsAI:
+			inbox[event.PlayerID] = append(inbox[event.PlayerID], event)
 		}
 	}
+	return shown
 }
 
 func (g *game) emergencySave() {
-	fmt.Println("Emergency save complete. Game state preserved.")
+	if err := g.saveGame(emergencySaveFile); err != nil {
+		fmt.Printf("⚠️ Emergency save failed: %v\n", err)
+		return
+	}
+	fmt.Printf("Emergency save complete. Game state preserved in %s.\n", emergencySaveFile)
 }
 
 func (g *game) endYear() error {
-	g.Year += 10
+	// Every civilization has had its say in this turn's world vote
+	if g.voteOpen() {
+		g.countVotes()
+	}
+	
+	g.Year += g.Settings.YearsPerTurn
 	g.TurnCount++
-	fmt.Printf("\n📅 Year advanced to %d BC\n", g.Year)
+	g.logEvent(-1, "\n📅 Year advanced to %s", formatYear(g.Year))
 	
+	g.healUnits()
+	g.refreshMovement()
+	g.assignWorkedTiles()
+	g.updateDiplomacy()
 	for _, player := range g.Players {
 		if err := g.updatePlayer(player); err != nil {
 			return fmt.Errorf("failed to update player %s: %w", player.Name, err)
 		}
 	}
+	// Borders follow the culture just gathered
+	g.updateTerritory()
 	return nil
 }
 
 func (g *game) updatePlayer(player *player) error {
-	for _, city := range player.Cities {
-		city.Population += rand.Intn(2)
-		city.Food += city.Population * 2
+	g.updateHappiness(player)
+	for _, city := range player.sortedCities() {
+		city.Culture += city.effects().Culture
 		
-		if len(city.ProductionQueue) > 0 {
-			item := &city.ProductionQueue[0]
-			item.Progress += 10 + city.Population
-			if item.Progress >= item.TotalCost {
-				if err := g.completeProduction(item, city, player); err != nil {
-					return fmt.Errorf("failed to complete production: %w", err)
-				}
-				city.ProductionQueue = city.ProductionQueue[1:]
-			}
+		g.growCity(city, player)
+		
+		if err := g.advanceProduction(city, player); err != nil {
+			return fmt.Errorf("failed to complete production: %w", err)
 		}
 	}
 	
-	if rand.Intn(100) < researchSuccessChance {
-		player.Techs[player.Researching] = true
-		fmt.Printf("🔬 %s researched %s!\n", player.Name, techToString(player.Researching))
-		player.Researching = g.chooseNextTech(player)
-	}
-	
+	g.advanceResearch(player)
+	g.collectGold(player)
 	return nil
 }
 
@@ -1022,20 +5048,44 @@ func (g *game) completeProduction(item *productionItem, city *city, player *play
 		}
 		
 		unit.X, unit.Y = x, y
+		unit.Experience = city.effects().Experience
 		player.Units[unit.ID] = unit
 		player.UnitCount++
 		g.Map[y][x].UnitID = unit.ID
-		g.Map[y][x].OwnerID = player.ID
-		fmt.Printf("🏭 %s produced a %s\n", city.Name, item.Name)
+		g.logEvent(player.ID, "🏭 %s produced a %s", city.Name, item.Name)
+		g.offerPromotion(unit)
 		
 	case productionBuilding:
 		buildingType := buildingType(item.ItemID)
 		city.Buildings = append(city.Buildings, buildingType)
-		fmt.Printf("🏗️ %s built a %s\n", city.Name, item.Name)
+		g.logEvent(player.ID, "🏗️ %s built a %s", city.Name, item.Name)
+		
+	case productionProject:
+		// A captured city may be partway through its old owner's project
+		project := projectType(item.ItemID)
+		if player.projectLocked(project) != nil {
+			g.logEvent(player.ID, "🚀 %s abandoned the %s, which is of no use to %s", city.Name, item.Name, player.Name)
+			return nil
+		}
+		player.Projects[project] = true
+		g.logEvent(-1, "🚀 %s completed the %s in %s", player.Name, item.Name, city.Name)
 	}
 	return nil
 }
 
+// unitStats are each unit type's whole moves per turn and strength.
+var unitStats = [unitCount]struct{ Movement, Strength int }{
+	unitSettler:   {2, 5},
+	unitWarrior:   {2, 10},
+	unitArcher:    {2, 8},
+	unitSwordsman: {2, 12},
+	unitKnight:    {3, 15},
+	unitMusketeer: {2, 18},
+	unitCannon:    {1, 25},
+	unitTank:      {3, 30},
+	unitWorker:    {2, 3},
+}
+
 func (g *game) createUnit(unitType unitType, player *player) (*unit, error) {
 	if !unitType.isValid() {
 		return nil, errInvalidUnit
@@ -1044,127 +5094,298 @@ func (g *game) createUnit(unitType unitType, player *player) (*unit, error) {
 	unit := &unit{
 		ID:      g.NextUnitID,
 		Type:    unitType,
-		Health:  100,
+		Health:  maxHealth,
 		OwnerID: player.ID,
 	}
 	g.NextUnitID++
 	
 	// Set unit properties
-	switch unitType {
-	case unitSettler:
-		unit.Movement, unit.Strength = 2, 5
-	case unitWarrior:
-		unit.Movement, unit.Strength = 2, 10
-	case unitArcher:
-		unit.Movement, unit.Strength = 2, 8
-	case unitSwordsman:
-		unit.Movement, unit.Strength = 2, 12
-	case unitKnight:
-		unit.Movement, unit.Strength = 3, 15
-	case unitMusketeer:
-		unit.Movement, unit.Strength = 2, 18
-	case unitCannon:
-		unit.Movement, unit.Strength = 1, 25
-	case unitTank:
-		unit.Movement, unit.Strength = 3, 30
-	}
+	unit.Strength = unitStats[unitType].Strength
+	unit.Movement = unit.maxMovement()
 	
 	return unit, nil
 }
 
 func (g *game) findUnitPlacement(city *city, player *player) (int, int, error) {
-	// Check adjacent tiles first
+	// The city itself, then adjacent tiles nobody else owns
+	if g.Map[city.Y][city.X].UnitID == -1 {
+		return city.X, city.Y, nil
+	}
 	directions := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
 	for _, dir := range directions {
-		x, y := (city.X+dir[0]+mapWidth)%mapWidth, (city.Y+dir[1]+mapHeight)%mapHeight
-		if g.Map[y][x].OwnerID == player.ID && g.Map[y][x].UnitID == -1 {
+		x, y := g.wrap(city.X+dir[0], city.Y+dir[1])
+		owner := g.Map[y][x].OwnerID
+		if (owner == player.ID || owner == -1) && g.Map[y][x].UnitID == -1 && g.isValidTile(x, y) {
 			return x, y, nil
 		}
 	}
 	
 	// Fallback: any player-owned tile
-	for y := 0; y < mapHeight; y++ {
-		for x := 0; x < mapWidth; x++ {
-			if g.Map[y][x].OwnerID == player.ID && g.Map[y][x].UnitID == -1 {
+	for y := 0; y < g.Settings.MapHeight; y++ {
+		for x := 0; x < g.Settings.MapWidth; x++ {
+			if g.Map[y][x].OwnerID == player.ID && g.Map[y][x].UnitID == -1 && g.isValidTile(x, y) {
 				return x, y, nil
 			}
 		}
 	}
-	return 0, 0, fmt.Errorf("no valid placement found for unit")
+	return 0, 0, errNoUnitPlacement
+}
+
+// chooseNextTech picks the cheapest technology whose prerequisites are met.
+func (g *game) chooseNextTech(player *player) techType {
+	next := techAgriculture
+	for tech := techAgriculture; tech < techCount; tech++ {
+		if player.canResearch(tech) && (next == techAgriculture || techTree[tech].Cost < techTree[next].Cost) {
+			next = tech
+		}
+	}
+	return next
+}
+
+// ========== Game State Checks ==========
+// Each victory condition is a check that names the winner once it is met.
+// The game ends on the first enabled one that is, taken in victoryOrder.
+const (
+	cultureVictoryPerTurn = 22 // culture to gather for each turn the game lasts
+	voteInterval          = 20 // turns between world votes
+	voteRelation          = 60 // goodwill a civilization needs to win another's vote
+)
+
+var victoryChecks = [victoryCount]func(g *game) (winnerID int, won bool){
+	victoryTime:       (*game).timeVictor,
+	victoryConquest:   (*game).conquestVictor,
+	victoryScience:    (*game).scienceVictor,
+	victoryCulture:    (*game).cultureVictor,
+	victoryDiplomatic: (*game).diplomaticVictor,
+}
+
+// victoryOrder settles victories won on the same turn: the time limit only
+// decides the game if nothing else has.
+var victoryOrder = []victoryType{victoryConquest, victoryScience, victoryCulture, victoryDiplomatic, victoryTime}
+
+// victoryDescriptions explain each victory condition in menus and at the
+// end of the game.
+var victoryDescriptions = [victoryCount]string{
+	victoryTime:       "highest score when the game ends",
+	victoryConquest:   "eliminate every rival",
+	victoryScience:    "complete the space program",
+	victoryCulture:    fmt.Sprintf("gather %d culture for every turn the game lasts", cultureVictoryPerTurn),
+	victoryDiplomatic: "win two thirds of a world vote",
+}
+
+func (g *game) checkGameOver() error {
+	for _, victory := range victoryOrder {
+		if !g.Settings.Victories[victory] {
+			continue
+		}
+		if winner, won := victoryChecks[victory](g); won {
+			for _, player := range g.Players {
+				player.Score = g.calculateScore(player)
+			}
+			g.WinnerID, g.Victory = winner, victory
+			return fmt.Errorf("%s victory achieved", strings.ToLower(victoryToString(victory)))
+		}
+	}
+	return nil
+}
+
+func (g *game) timeVictor() (int, bool) {
+	if g.Year < g.Settings.EndYear {
+		return -1, false
+	}
+	winner, highestScore := -1, -1
+	for i, player := range g.Players {
+		if player.Eliminated {
+			continue
+		}
+		if score := g.calculateScore(player); score > highestScore {
+			winner, highestScore = i, score
+		}
+	}
+	return winner, true
+}
+
+func (g *game) conquestVictor() (int, bool) {
+	alivePlayers := 0
+	lastAlive := -1
+	for i, player := range g.Players {
+		if !player.Eliminated {
+			alivePlayers++
+			lastAlive = i
+		}
+	}
+	return lastAlive, alivePlayers == 1
 }
 
-func (g *game) chooseNextTech(player *player) techType {
-	for tech := techAgriculture; tech < techCount; tech++ {
-		if !player.Techs[tech] {
-			return tech
+func (g *game) scienceVictor() (int, bool) {
+	for i, player := range g.Players {
+		if player.Projects[projectCount-1] {
+			return i, true
 		}
 	}
-	return techAgriculture
+	return -1, false
 }
 
-// ========== Game State Checks ==========
-func (g *game) checkGameOver() error {
-	if g.Year >= endYear {
-		return g.determineTimeVictory()
+// totalCulture is the culture gathered by all the player's cities.
+func (p *player) totalCulture() int {
+	total := 0
+	for _, city := range p.Cities {
+		total += city.Culture
 	}
-	return g.checkConquestVictory()
+	return total
+}
+
+// cultureVictoryPoints is the culture a civilization's cities must gather
+// for a culture victory. It grows with the length of the game; with the
+// default settings the most cultured AI civilizations reach it, if at
+// all, in the last centuries before the time limit.
+func (s gameSettings) cultureVictoryPoints() int {
+	return cultureVictoryPerTurn * s.turns()
 }
 
-func (g *game) determineTimeVictory() error {
-	highestScore := -1
+func (g *game) cultureVictor() (int, bool) {
 	for i, player := range g.Players {
-		score := g.calculateScore(player)
-		player.Score = score
-		if score > highestScore {
-			highestScore = score
-			g.WinnerID = i
+		if !player.Eliminated && player.totalCulture() >= g.Settings.cultureVictoryPoints() {
+			return i, true
 		}
 	}
-	return fmt.Errorf("time victory achieved")
+	return -1, false
 }
 
-func (g *game) checkConquestVictory() error {
-	alivePlayers := 0
-	lastAlive := -1
-	for i, player := range g.Players {
-		if player.CityCount > 0 {
-			alivePlayers++
-			lastAlive = i
+// isVoteTurn reports whether the world votes this turn.
+func (g *game) isVoteTurn() bool {
+	return g.TurnCount > 0 && g.TurnCount%voteInterval == 0
+}
+
+// voteOpen reports whether ballots can be cast: on a vote turn, while
+// diplomatic victory is enabled. Each civilization votes during its own
+// turn and the ballots are counted once all have moved.
+func (g *game) voteOpen() bool {
+	return g.Settings.Victories[victoryDiplomatic] && g.isVoteTurn()
+}
+
+// aiVoteChoice is who an AI civilization votes for: the rival at peace
+// with it that it regards most warmly, if any has earned voteRelation, and
+// otherwise itself.
+func (g *game) aiVoteChoice(voter *player) int {
+	choice, warmest := voter.ID, voteRelation-1
+	for _, other := range g.Players {
+		if other.ID == voter.ID || other.Eliminated || g.stance(voter.ID, other.ID) != stancePeace {
+			continue
+		}
+		if relation := voter.Relations[other.ID]; relation > warmest {
+			choice, warmest = other.ID, relation
 		}
 	}
-	
-	if alivePlayers == 1 {
-		g.WinnerID = lastAlive
-		return fmt.Errorf("conquest victory achieved")
+	return choice
+}
+
+// countVotes counts this turn's ballots, announces the result and empties
+// the ballot box for the next vote. Civilizations that didn't vote, or
+// have since been eliminated, abstain.
+func (g *game) countVotes() {
+	g.VoteTally = make([]int, len(g.Players))
+	abstained := 0
+	for _, voter := range g.Players {
+		if voter.Eliminated {
+			continue
+		}
+		candidate, voted := g.Ballots[voter.ID]
+		if !voted || g.Players[candidate].Eliminated {
+			abstained++
+			continue
+		}
+		g.VoteTally[candidate]++
 	}
+	g.VoteTurn = g.TurnCount
+	g.Ballots = make(map[int]int)
 	
-	return nil
+	results := make([]string, 0, len(g.Players)+1)
+	for i, n := range g.VoteTally {
+		if n > 0 {
+			results = append(results, fmt.Sprintf("%s %d", g.Players[i].Name, n))
+		}
+	}
+	if abstained > 0 {
+		results = append(results, fmt.Sprintf("%d abstained", abstained))
+	}
+	g.logEvent(-1, "🗳️ World vote: %s", strings.Join(results, ", "))
+}
+
+// diplomaticVictor looks at the vote counted when the last turn ended; a
+// civilization wins with the votes of two thirds of those still standing.
+func (g *game) diplomaticVictor() (int, bool) {
+	if g.VoteTally == nil || g.VoteTurn != g.TurnCount-1 {
+		return -1, false
+	}
+	alive := 0
+	for _, player := range g.Players {
+		if !player.Eliminated {
+			alive++
+		}
+	}
+	for i, n := range g.VoteTally {
+		if alive >= 2 && n*3 >= alive*2 && !g.Players[i].Eliminated {
+			return i, true
+		}
+	}
+	return -1, false
 }
 
 func (g *game) calculateScore(player *player) int {
 	score := player.CityCount * 100
 	score += len(player.Techs) * 50
 	score += player.UnitCount * 10
+	for _, city := range player.Cities {
+		score += len(city.Buildings) * 20
+		score += city.Culture / 5
+	}
 	
-	for y := 0; y < mapHeight; y++ {
-		for x := 0; x < mapWidth; x++ {
-			if g.Map[y][x].OwnerID == player.ID {
-				score += 5
+	score += g.territorySize(player) * 5
+	
+	return score
+}
+
+// displayVictoryProgress shows how close the player is to each enabled
+// victory besides conquest and time.
+func (g *game) displayVictoryProgress(player *player) {
+	if g.Settings.Victories[victoryScience] {
+		stages := 0
+		for _, done := range player.Projects {
+			if done {
+				stages++
 			}
 		}
+		fmt.Printf("🚀 Space program: %d/%d stages\n", stages, projectCount)
+	}
+	if g.Settings.Victories[victoryCulture] {
+		fmt.Printf("🎭 Culture: %d/%d\n", player.totalCulture(), g.Settings.cultureVictoryPoints())
+	}
+	if g.voteOpen() {
+		fmt.Println("🗳️ The world votes this turn")
+	} else if g.Settings.Victories[victoryDiplomatic] {
+		turns := voteInterval - g.TurnCount%voteInterval
+		fmt.Printf("🗳️ Next world vote: %s\n", formatYear(g.Year+turns*g.Settings.YearsPerTurn))
 	}
-	
-	return score
 }
 
 func (g *game) displayWinner() {
+	if g.WinnerID == -1 {
+		fmt.Printf("\n💀 Every civilization has fallen by %s. Nobody wins.\n", formatYear(g.Year))
+		return
+	}
 	winner := g.Players[g.WinnerID]
-	fmt.Printf("\n🏆 Victory! %s wins in %d BC!\n", winner.Name, g.Year)
+	fmt.Printf("\n🏆 %s Victory! %s wins in %s!\n", victoryToString(g.Victory), winner.Name, formatYear(g.Year))
+	fmt.Printf("(%s)\n", victoryDescriptions[g.Victory])
 	fmt.Printf("Final Score: %d\n", winner.Score)
 	
 	fmt.Println("\nFinal Scores:")
 	for _, player := range g.Players {
-		fmt.Printf("%s: %d\n", player.Name, player.Score)
+		fmt.Printf("%s: %d", player.Name, player.Score)
+		if player.Eliminated {
+			fmt.Print(" (eliminated)")
+		}
+		fmt.Println()
 	}
 }
 
@@ -1175,11 +5396,18 @@ func (g *game) playerTurn(player *player, validator *inputValidator) error {
 			"View Map",
 			"Manage Cities",
 			"Move Units",
+			"Next Idle Unit",
 			"Found City",
 			"Research Technology",
+			"Diplomacy",
 			"View Status",
+			"Save Game",
+			"Load Game",
 			"End Turn",
 		})
+		if errors.Is(err, errInputClosed) {
+			return err
+		}
 		if err != nil {
 			fmt.Printf("Invalid input: %v\n", err)
 			continue
@@ -1197,16 +5425,34 @@ func (g *game) playerTurn(player *player, validator *inputValidator) error {
 				fmt.Printf("Unit movement error: %v\n", err)
 			}
 		case 4:
+			if err := g.idleUnitsMenu(player, validator); err != nil {
+				fmt.Printf("Unit orders error: %v\n", err)
+			}
+		case 5:
 			if err := g.foundCity(player, validator); err != nil {
 				fmt.Printf("City founding error: %v\n", err)
 			}
-		case 5:
+		case 6:
 			if err := g.researchTech(player, validator); err != nil {
 				fmt.Printf("Research error: %v\n", err)
 			}
-		case 6:
-			g.displayStatus(player)
 		case 7:
+			if err := g.diplomacyMenu(player, validator); err != nil {
+				fmt.Printf("Diplomacy error: %v\n", err)
+			}
+		case 8:
+			g.displayStatus(player)
+		case 9:
+			if err := g.saveGameMenu(validator); err != nil {
+				fmt.Printf("Save error: %v\n", err)
+			}
+		case 10:
+			if err := g.loadGameMenu(validator); err != nil {
+				fmt.Printf("Load error: %v\n", err)
+				continue
+			}
+			return errGameLoaded
+		case 11:
 			fmt.Println("Ending turn...")
 			return nil
 		}
@@ -1218,8 +5464,9 @@ func (g *game) manageCities(player *player, validator *inputValidator) error {
 		return fmt.Errorf("no cities to manage")
 	}
 	
-	cityList := make([]string, 0, player.CityCount)
-	for _, city := range player.Cities {
+	cities := player.sortedCities()
+	cityList := make([]string, 0, len(cities))
+	for _, city := range cities {
 		cityList = append(cityList, fmt.Sprintf("%s (Pop: %d)", city.Name, city.Population))
 	}
 	
@@ -1228,68 +5475,90 @@ func (g *game) manageCities(player *player, validator *inputValidator) error {
 		return err
 	}
 	
-	// Get city by index
-	var selectedCity *city
-	i := 0
-	for _, c := range player.Cities {
-		if i == choice-1 {
-			selectedCity = c
-			break
-		}
-		i++
-	}
-	
-	if selectedCity == nil {
-		return errCityNotFound
-	}
+	selectedCity := cities[choice-1]
 	
 	return g.cityManagementMenu(selectedCity, player, validator)
 }
 
 func (g *game) cityManagementMenu(city *city, player *player, validator *inputValidator) error {
+	// The space program is only offered when it can win the game
+	options := []string{"View Info", "Produce Unit", "Build Building"}
+	if g.Settings.Victories[victoryScience] {
+		options = append(options, "Space Program")
+	}
+	options = append(options, "View Queue", "Buy Production", "Back")
+	
 	for {
-		choice, err := validator.getChoiceInput(fmt.Sprintf("\n🏙️ Managing %s", city.Name), []string{
-			"View Info",
-			"Produce Unit",
-			"Build Building",
-			"View Queue",
-			"Back",
-		})
+		choice, err := validator.getChoiceInput(fmt.Sprintf("\n🏙️ Managing %s", city.Name), options)
 		if err != nil {
 			return err
 		}
 		
-		switch choice {
-		case 1:
+		switch options[choice-1] {
+		case "View Info":
 			g.displayCityInfo(city)
-		case 2:
-			if err := g.produceUnit(city, validator); err != nil {
+		case "Produce Unit":
+			if err := g.produceUnit(player, city, validator); err != nil {
 				return err
 			}
-		case 3:
-			if err := g.buildBuilding(city, validator); err != nil {
+		case "Build Building":
+			if err := g.buildBuilding(player, city, validator); err != nil {
 				return err
 			}
-		case 4:
+		case "Space Program":
+			if err := g.buildProject(player, city, validator); err != nil {
+				fmt.Printf("Space program error: %v\n", err)
+			}
+		case "View Queue":
 			g.displayProductionQueue(city)
-		case 5:
+		case "Buy Production":
+			if err := g.buyProduction(player, city, validator); err != nil {
+				fmt.Printf("Buy error: %v\n", err)
+			}
+		case "Back":
 			return nil
 		}
 	}
 }
 
+func (g *game) buyProduction(player *player, city *city, validator *inputValidator) error {
+	if len(city.ProductionQueue) == 0 {
+		return errNothingToBuy
+	}
+	
+	item := city.ProductionQueue[0]
+	price := buyPrice(item)
+	confirm, err := validator.getChoiceInput(fmt.Sprintf("\n💰 Buy %s for %d gold? (You have %d)", item.Name, price, player.Gold), []string{"Yes", "No"})
+	if err != nil || confirm != 1 {
+		return err
+	}
+	
+	if _, err := g.BuyProduction(player.ID, city.ID); err != nil {
+		return err
+	}
+	fmt.Printf("💰 Bought %s for %d gold. It will be ready next turn.\n", item.Name, price)
+	return nil
+}
+
 func (g *game) displayCityInfo(city *city) {
 	fmt.Printf("\n🏙️ %s\n", city.Name)
 	fmt.Printf("Population: %d\n", city.Population)
-	fmt.Printf("Food: %d\n", city.Food)
-	fmt.Printf("Production: %d\n", city.Production)
+	fmt.Printf("Food: %d/%d (%+d per turn, %d tiles worked)\n", city.Food, foodBox(city.Population), g.foodSurplus(city), len(city.WorkedTiles))
+	fmt.Printf("Production: %d shields per turn\n", g.cityProduction(city))
+	fmt.Printf("Culture: %d\n", city.Culture)
+	content, unhappy := g.cityMood(city, g.Players[city.OwnerID])
+	fmt.Printf("Mood: %d content, %d unhappy", content, unhappy)
+	if city.UnrestTurns > 0 {
+		fmt.Printf(" (disorder for %d turns)", city.UnrestTurns)
+	}
+	fmt.Println()
 	
 	fmt.Println("\nBuildings:")
 	if len(city.Buildings) == 0 {
 		fmt.Println("None")
 	} else {
 		for _, building := range city.Buildings {
-			fmt.Printf("- %s\n", buildingToString(building))
+			fmt.Printf("- %s (%s)\n", buildingToString(building), buildingEffects[building])
 		}
 	}
 	
@@ -1304,29 +5573,453 @@ func (g *game) displayProductionQueue(city *city) {
 	}
 	
 	for i, item := range city.ProductionQueue {
-		fmt.Printf("%d. %s: %d/%d\n", i+1, item.Name, item.Progress, item.TotalCost)
+		fmt.Printf("%d. %s: %d/%d", i+1, item.Name, item.Progress, item.TotalCost)
+		if shields := g.cityProduction(city); i == 0 && shields > 0 {
+			fmt.Printf(" (%d turns)", (max(item.TotalCost-item.Progress, 0)+shields-1)/shields)
+		}
+		fmt.Println()
+	}
+}
+
+// ========== Save/Load ==========
+// saveFile is the on-disk envelope for a saved game. The game struct is
+// stored as-is so every exported field is part of the save format.
+type saveFile struct {
+	Version  int
+	SavedAt  time.Time
+	Game     *game
+	RNGState []byte
+}
+
+// saveMigrations bring a game saved in an older format up to the next
+// version, indexed by the version they upgrade from. Each change to the
+// save format bumps saveFileVersion and adds a step here.
+var saveMigrations = []func(g *game){
+	// Version 2 stores the game settings, and counts years from 0 with BC
+	// years negative. Version 1 games were always 20x15 and counted up
+	// from 4000 while being shown as BC. Resources were and still are
+	// written by name.
+	1: func(g *game) {
+		g.Settings = defaultSettings()
+		g.Settings.Players = len(g.Players)
+		g.Settings.AIPlayers = 0
+		for _, player := range g.Players {
+			if player != nil && player.IsAI {
+				g.Settings.AIPlayers++
+			}
+		}
+		g.Year -= 8000
+	},
+	// Version 3 counts movement points in sixths of a move.
+	2: func(g *game) {
+		for _, player := range g.Players {
+			if player == nil {
+				continue
+			}
+			for _, unit := range player.Units {
+				unit.Movement *= movementScale
+			}
+		}
+	},
+	// Version 4 keeps the world vote's ballots and last result. Older
+	// games start with an empty ballot box, which loading sets up.
+	3: func(g *game) {},
+}
+
+func (g *game) saveGame(path string) error {
+	rngState, err := g.rngSource.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode random state: %w", err)
+	}
+	
+	data, err := json.MarshalIndent(saveFile{
+		Version:  saveFileVersion,
+		SavedAt:  time.Now(),
+		Game:     g,
+		RNGState: rngState,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode game: %w", err)
+	}
+
+	// Write to a temporary file first so a crash mid-write never
+	// clobbers an existing save.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write save file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write save file: %w", err)
+	}
+	return nil
+}
+
+func loadGame(path string) (*game, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read save file: %w", err)
+	}
+
+	var save saveFile
+	if err := json.Unmarshal(data, &save); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptSave, err)
 	}
+	if save.Version < 1 || save.Version > saveFileVersion {
+		return nil, fmt.Errorf("%w: got %d, want 1 to %d", errSaveVersion, save.Version, saveFileVersion)
+	}
+	if save.Game == nil {
+		return nil, fmt.Errorf("%w: missing game state", errCorruptSave)
+	}
+	for version := save.Version; version < saveFileVersion; version++ {
+		saveMigrations[version](save.Game)
+	}
+
+	if err := save.Game.validateLoaded(); err != nil {
+		return nil, err
+	}
+	
+	// Restore the generator mid-stream so the loaded game continues exactly
+	// as the saved one would have. Saves without random state fall back to
+	// a fresh generator from the recorded seed.
+	save.Game.seedRNG(save.Game.Seed)
+	if len(save.RNGState) > 0 {
+		if err := save.Game.rngSource.UnmarshalBinary(save.RNGState); err != nil {
+			return nil, fmt.Errorf("%w: random state: %v", errCorruptSave, err)
+		}
+	}
+	return save.Game, nil
+}
+
+// validateLoaded checks the invariants the rest of the engine relies on
+// and restores empty maps that JSON decodes as nil.
+func (g *game) validateLoaded() error {
+	if err := g.Settings.validate(); err != nil {
+		return fmt.Errorf("%w: %v", errCorruptSave, err)
+	}
+	
+	if len(g.Map) != g.Settings.MapHeight {
+		return fmt.Errorf("%w: map has %d rows, want %d", errCorruptSave, len(g.Map), g.Settings.MapHeight)
+	}
+	for y, row := range g.Map {
+		if len(row) != g.Settings.MapWidth {
+			return fmt.Errorf("%w: map row %d has %d columns, want %d", errCorruptSave, y, len(row), g.Settings.MapWidth)
+		}
+		for x, t := range row {
+			if !t.Terrain.isValid() {
+				return fmt.Errorf("%w: tile (%d,%d): %v", errCorruptSave, x, y, errInvalidTerrain)
+			}
+		}
+	}
+
+	if len(g.Players) != g.Settings.Players {
+		return fmt.Errorf("%w: %d players", errCorruptSave, len(g.Players))
+	}
+	if g.CurrentPlayerIndex < 0 || g.CurrentPlayerIndex >= len(g.Players) {
+		return fmt.Errorf("%w: current player %d", errCorruptSave, g.CurrentPlayerIndex)
+	}
+
+	for i, player := range g.Players {
+		if player == nil || player.ID != i {
+			return fmt.Errorf("%w: player %d", errCorruptSave, i)
+		}
+		if player.Cities == nil {
+			player.Cities = make(map[int]*city, maxCities)
+		}
+		if player.Units == nil {
+			player.Units = make(map[int]*unit, maxUnits)
+		}
+		if player.Techs == nil {
+			player.Techs = make(map[techType]bool)
+		}
+		if player.Relations == nil {
+			player.Relations = make(map[int]int)
+		}
+		if player.Treaties == nil {
+			player.Treaties = make(map[int]treaty)
+		}
+		player.CityCount = len(player.Cities)
+		player.UnitCount = len(player.Units)
+		if err := g.validatePlayer(player); err != nil {
+			return fmt.Errorf("%w: %s: %v", errCorruptSave, player.Name, err)
+		}
+	}
+	if err := g.validateTiles(); err != nil {
+		return fmt.Errorf("%w: %v", errCorruptSave, err)
+	}
+	if g.VoteTally != nil && len(g.VoteTally) != len(g.Players) {
+		return fmt.Errorf("%w: vote tally has %d entries for %d players", errCorruptSave, len(g.VoteTally), len(g.Players))
+	}
+	if g.Ballots == nil {
+		g.Ballots = make(map[int]int)
+	}
+	for voter, candidate := range g.Ballots {
+		if !g.isPlayerID(voter) || !g.isPlayerID(candidate) {
+			return fmt.Errorf("%w: ballot %d for %d", errCorruptSave, voter, candidate)
+		}
+	}
+	g.updateTerritory()
+	for _, player := range g.Players {
+		g.updateVisibility(player)
+	}
+
+	g.Running = true
+	return nil
+}
+
+func (g *game) isPlayerID(id int) bool {
+	return id >= 0 && id < len(g.Players)
+}
+
+// onMap reports whether (x, y) is inside the map, whatever its terrain.
+func (g *game) onMap(x, y int) bool {
+	return x >= 0 && x < g.Settings.MapWidth && y >= 0 && y < g.Settings.MapHeight
+}
+
+// validatePlayer checks that what a loaded player refers to exists: known
+// technologies, other players, and cities and units that are its own,
+// on the map and standing where the map says they are.
+func (g *game) validatePlayer(p *player) error {
+	if !p.Researching.isValid() {
+		return fmt.Errorf("researching technology %d", p.Researching)
+	}
+	for tech := range p.Techs {
+		if !tech.isValid() {
+			return fmt.Errorf("knows technology %d", tech)
+		}
+	}
+	for id := range p.Relations {
+		if !g.isPlayerID(id) {
+			return fmt.Errorf("relations with player %d", id)
+		}
+	}
+	for id := range p.Treaties {
+		if !g.isPlayerID(id) {
+			return fmt.Errorf("treaty with player %d", id)
+		}
+	}
+	if p.Memory != nil {
+		if len(p.Memory) != g.Settings.MapHeight {
+			return fmt.Errorf("remembers %d map rows", len(p.Memory))
+		}
+		for y, row := range p.Memory {
+			if len(row) != g.Settings.MapWidth {
+				return fmt.Errorf("remembers %d columns in map row %d", len(row), y)
+			}
+		}
+	}
+	
+	for id, c := range p.Cities {
+		switch {
+		case c == nil || id < 0 || id >= g.NextCityID:
+			return fmt.Errorf("city %d", id)
+		case c.ID != id:
+			return fmt.Errorf("city %d is filed under ID %d", c.ID, id)
+		case c.OwnerID != p.ID:
+			return fmt.Errorf("city %d is owned by player %d", id, c.OwnerID)
+		case !g.onMap(c.X, c.Y):
+			return fmt.Errorf("city %d at (%d,%d) is off the map", id, c.X, c.Y)
+		case g.Map[c.Y][c.X].CityID != id:
+			return fmt.Errorf("city %d isn't on its tile (%d,%d)", id, c.X, c.Y)
+		}
+		for _, pos := range c.WorkedTiles {
+			if !g.onMap(pos.X, pos.Y) {
+				return fmt.Errorf("city %d works (%d,%d), off the map", id, pos.X, pos.Y)
+			}
+		}
+	}
+	for id, u := range p.Units {
+		switch {
+		case u == nil || id < 0 || id >= g.NextUnitID:
+			return fmt.Errorf("unit %d", id)
+		case u.ID != id:
+			return fmt.Errorf("unit %d is filed under ID %d", u.ID, id)
+		case !u.Type.isValid():
+			return fmt.Errorf("unit %d has type %d", id, u.Type)
+		case u.OwnerID != p.ID:
+			return fmt.Errorf("unit %d is owned by player %d", id, u.OwnerID)
+		case !g.onMap(u.X, u.Y):
+			return fmt.Errorf("unit %d at (%d,%d) is off the map", id, u.X, u.Y)
+		case g.Map[u.Y][u.X].UnitID != id:
+			return fmt.Errorf("unit %d isn't on its tile (%d,%d)", id, u.X, u.Y)
+		case u.Destination != nil && !g.onMap(u.Destination.X, u.Destination.Y):
+			return fmt.Errorf("unit %d is heading off the map", id)
+		}
+	}
+	return nil
+}
+
+// validateTiles checks that every city, unit and owner a tile names
+// exists, so nothing on the map is left over from a player's lost state.
+func (g *game) validateTiles() error {
+	for y, row := range g.Map {
+		for x, t := range row {
+			if t.OwnerID != -1 && !g.isPlayerID(t.OwnerID) {
+				return fmt.Errorf("tile (%d,%d) is owned by player %d", x, y, t.OwnerID)
+			}
+			if t.CityID != -1 {
+				if c, err := g.findCity(t.CityID); err != nil || c.X != x || c.Y != y {
+					return fmt.Errorf("tile (%d,%d) holds missing city %d", x, y, t.CityID)
+				}
+			}
+			if t.UnitID != -1 {
+				if u, err := g.findUnit(t.UnitID); err != nil || u.X != x || u.Y != y {
+					return fmt.Errorf("tile (%d,%d) holds missing unit %d", x, y, t.UnitID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *game) saveGameMenu(validator *inputValidator) error {
+	path, err := validator.getStringInput(fmt.Sprintf("Enter save file name (blank for %s): ", defaultSaveFile), 0, 100)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = defaultSaveFile
+	}
+
+	if err := g.saveGame(path); err != nil {
+		return err
+	}
+	fmt.Printf("💾 Game saved to %s\n", path)
+	return nil
+}
+
+func (g *game) loadGameMenu(validator *inputValidator) error {
+	path, err := validator.getStringInput(fmt.Sprintf("Enter save file name (blank for %s): ", defaultSaveFile), 0, 100)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = defaultSaveFile
+	}
+
+	loaded, err := loadGame(path)
+	if err != nil {
+		return err
+	}
+	*g = *loaded
+	fmt.Printf("📂 Loaded game from %s (%s)\n", path, formatYear(g.Year))
+	return nil
 }
 
 // ========== Main Function ==========
+// fillFlagDefaults fills in the settings whose defaults depend on other
+// flags: unless -ai is given, the computer plays every civilization but
+// the first, however many -players asks for.
+func fillFlagDefaults(settings gameSettings, given []string) gameSettings {
+	if !slices.Contains(given, "ai") {
+		settings.AIPlayers = settings.Players - 1
+	}
+	return settings
+}
+
+// checkLoadFlags rejects setup flags given alongside -load. A saved game
+// keeps the seed and settings it was started with, so they would be
+// silently ignored.
+func checkLoadFlags(given []string) error {
+	for _, name := range given {
+		if name != "load" {
+			return fmt.Errorf("-%s can't be used with -load: a saved game keeps its own seed and settings", name)
+		}
+	}
+	return nil
+}
+
 func main() {
+	defaults := defaultSettings()
+	loadPath := flag.String("load", "", "resume a saved game from the given file")
+	seed := flag.Uint64("seed", 0, "random seed for a reproducible game (0 picks one)")
+	mapType := flag.String("map", mapGeneratorNames[defaults.MapGenerator], "map generator: continents or random")
+	width := flag.Int("width", defaults.MapWidth, fmt.Sprintf("map width (%d-%d)", minMapWidth, maxMapWidth))
+	height := flag.Int("height", defaults.MapHeight, fmt.Sprintf("map height (%d-%d)", minMapHeight, maxMapHeight))
+	players := flag.Int("players", defaults.Players, fmt.Sprintf("number of civilizations (2-%d)", maxPlayers))
+	aiPlayers := flag.Int("ai", defaults.AIPlayers, "how many civilizations the computer plays (all but one unless given)")
+	startYear := flag.Int("start-year", defaults.StartYear, fmt.Sprintf("year the game starts (%d-%d, negative for BC)", minGameYear, maxGameYear-1))
+	endYear := flag.Int("end-year", defaults.EndYear, fmt.Sprintf("year the game ends (up to %d, negative for BC)", maxGameYear))
+	turnYears := flag.Int("turn-years", defaults.YearsPerTurn, fmt.Sprintf("years that pass each turn (1-%d)", maxYearsPerTurn))
+	var noVictory [victoryCount]*bool
+	for v := range noVictory {
+		name := strings.ToLower(victoryToString(victoryType(v)))
+		noVictory[v] = flag.Bool("no-"+name+"-victory", false, fmt.Sprintf("disable %s victory (%s)", name, victoryDescriptions[v]))
+	}
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags]\n\n", os.Args[0])
+		fmt.Fprintln(out, "With no flags, or only -seed, the game asks for its settings. Any other")
+		fmt.Fprintln(out, "setup flag skips those questions and starts at once, with the defaults")
+		fmt.Fprintln(out, "below for every setting not given.")
+		fmt.Fprintln(out)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	
+	generator, err := parseMapGenerator(*mapType)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	
+	var given []string
+	flag.Visit(func(f *flag.Flag) {
+		given = append(given, f.Name)
+	})
+	
 	scanner := bufio.NewScanner(os.Stdin)
 	validator := newInputValidator(scanner)
 	
 	fmt.Println("🏛️ Civilization Game")
 	
-	numPlayers, err := validator.getIntInput("Enter number of players (2-8): ", 2, 8)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		fmt.Println("Using default: 4 players")
-		numPlayers = 4
+	if *loadPath != "" {
+		if err := checkLoadFlags(given); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		game, err := loadGame(*loadPath)
+		if err != nil {
+			fmt.Printf("Failed to load game: %v\n", err)
+			return
+		}
+		game.Run(validator)
+		return
+	}
+	
+	settings := defaults
+	settings.MapGenerator = generator
+	settings.MapWidth, settings.MapHeight = *width, *height
+	settings.Players, settings.AIPlayers = *players, *aiPlayers
+	settings.StartYear, settings.EndYear, settings.YearsPerTurn = *startYear, *endYear, *turnYears
+	for v, disabled := range noVictory {
+		settings.Victories[v] = !*disabled
+	}
+	settings = fillFlagDefaults(settings, given)
+	
+	// Without any setup flags, ask for the settings instead.
+	configured := false
+	for _, name := range given {
+		if name != "seed" {
+			configured = true
+		}
+	}
+	if configured {
+		fmt.Println("Using the settings from the command line; run with -h to see the defaults for the rest.")
+	} else {
+		settings, err = settingsMenu(settings, validator)
+		if err != nil {
+			fmt.Printf("Invalid settings: %v\n", err)
+			return
+		}
 	}
 	
-	game, err := newGame(numPlayers)
+	game, err := newGame(settings, *seed)
 	if err != nil {
 		fmt.Printf("Failed to initialize game: %v\n", err)
 		return
 	}
+	fmt.Printf("🎲 Game seed: %d\n", game.Seed)
 	
-	game.Run()
+	game.Run(validator)
 }
@@ -0,0 +1,2402 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errorCode is the code of the gameError wrapped in err, if any.
+func errorCode(err error) string {
+	var gameErr gameError
+	if errors.As(err, &gameErr) {
+		return gameErr.Code
+	}
+	return ""
+}
+
+func expectCode(t *testing.T, what string, err error, code string) {
+	t.Helper()
+	if got := errorCode(err); got != code {
+		t.Errorf("%s: got error %v, want code %s", what, err, code)
+	}
+}
+
+// aiSettings is a small game played entirely by the computer.
+func aiSettings(players int) gameSettings {
+	settings := defaultSettings()
+	settings.Players, settings.AIPlayers = players, players
+	return settings
+}
+
+func newTestGame(t *testing.T, settings gameSettings, seed uint64) *game {
+	t.Helper()
+	g, err := newGame(settings, seed)
+	if err != nil {
+		t.Fatalf("newGame: %v", err)
+	}
+	return g
+}
+
+// playTurns has the AI play the next n player turns.
+func playTurns(t *testing.T, g *game, n int) {
+	t.Helper()
+	for i := 0; i < n && g.Running; i++ {
+		p := g.Players[g.CurrentPlayerIndex]
+		if err := g.aiTurn(p); err != nil {
+			t.Fatalf("aiTurn: %v", err)
+		}
+		if _, err := g.EndTurn(p.ID); err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+	}
+}
+
+// snapshot is everything that makes up a game's state, random generator
+// included.
+func snapshot(t *testing.T, g *game) []byte {
+	t.Helper()
+	state, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("marshal game: %v", err)
+	}
+	rng, err := g.rngSource.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal rng: %v", err)
+	}
+	return append(state, rng...)
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+	for _, gen := range []mapGenerator{mapGenContinents, mapGenRandom} {
+		settings := aiSettings(4)
+		settings.MapGenerator = gen
+		a := newTestGame(t, settings, 42)
+		b := newTestGame(t, settings, 42)
+		playTurns(t, a, 4*60)
+		playTurns(t, b, 4*60)
+		if !bytes.Equal(snapshot(t, a), snapshot(t, b)) {
+			t.Errorf("%s: two games from seed 42 diverged", mapGeneratorNames[gen])
+		}
+	}
+
+	c := newTestGame(t, aiSettings(4), 43)
+	d := newTestGame(t, aiSettings(4), 42)
+	if bytes.Equal(snapshot(t, c), snapshot(t, d)) {
+		t.Error("seeds 42 and 43 produced the same game")
+	}
+}
+
+func TestSaveLoadContinuesIdentically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.json")
+	uninterrupted := newTestGame(t, aiSettings(3), 7)
+	interrupted := newTestGame(t, aiSettings(3), 7)
+
+	playTurns(t, uninterrupted, 3*30)
+	playTurns(t, interrupted, 3*30)
+	if err := interrupted.saveGame(path); err != nil {
+		t.Fatalf("saveGame: %v", err)
+	}
+	resumed, err := loadGame(path)
+	if err != nil {
+		t.Fatalf("loadGame: %v", err)
+	}
+
+	playTurns(t, uninterrupted, 3*30)
+	playTurns(t, resumed, 3*30)
+	if !bytes.Equal(snapshot(t, uninterrupted), snapshot(t, resumed)) {
+		t.Error("a saved and reloaded game diverged from the uninterrupted one")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	g := newTestGame(t, aiSettings(3), 1)
+	g.Players[1].Gold = 123
+	g.CurrentPlayerIndex = 2
+	path := filepath.Join(t.TempDir(), "save.json")
+	if err := g.saveGame(path); err != nil {
+		t.Fatalf("saveGame: %v", err)
+	}
+
+	loaded, err := loadGame(path)
+	if err != nil {
+		t.Fatalf("loadGame: %v", err)
+	}
+	want, _ := json.Marshal(g)
+	got, _ := json.Marshal(loaded)
+	if !bytes.Equal(got, want) {
+		t.Error("the loaded game differs from the saved one")
+	}
+	if loaded.Players[1].CityCount != len(loaded.Players[1].Cities) {
+		t.Errorf("city count %d, want %d", loaded.Players[1].CityCount, len(loaded.Players[1].Cities))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	os.WriteFile(corrupt, []byte("{not json"), 0o644)
+	_, err := loadGame(corrupt)
+	expectCode(t, "corrupt save", err, "CORRUPT_SAVE")
+
+	future := filepath.Join(dir, "future.json")
+	os.WriteFile(future, []byte(`{"Version": 999, "Game": {}}`), 0o644)
+	_, err = loadGame(future)
+	expectCode(t, "newer save", err, "SAVE_VERSION")
+
+	empty := filepath.Join(dir, "empty.json")
+	os.WriteFile(empty, []byte(`{"Version": 1}`), 0o644)
+	_, err = loadGame(empty)
+	expectCode(t, "save without a game", err, "CORRUPT_SAVE")
+
+	small := filepath.Join(dir, "small.json")
+	os.WriteFile(small, []byte(`{"Version": 1, "Game": {"Map": [[]]}}`), 0o644)
+	_, err = loadGame(small)
+	expectCode(t, "save with a truncated map", err, "CORRUPT_SAVE")
+}
+
+func TestLoadRejectsBrokenState(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt func(g *game)
+	}{
+		{"unknown research", func(g *game) { g.Players[0].Researching = techCount }},
+		{"unknown technology", func(g *game) { g.Players[0].Techs[techCount+3] = true }},
+		{"city off the map", func(g *game) { g.Players[0].sortedCities()[0].X = g.Settings.MapWidth }},
+		{"unit off the map", func(g *game) { g.Players[1].sortedUnits()[0].Y = -1 }},
+		{"unit owned by someone else", func(g *game) { g.Players[1].sortedUnits()[0].OwnerID = 0 }},
+		{"unit away from its tile", func(g *game) {
+			u := g.Players[0].sortedUnits()[0]
+			g.Map[u.Y][u.X].UnitID = -1
+		}},
+		{"tile naming a missing unit", func(g *game) { g.Map[0][0].UnitID = g.NextUnitID + 5 }},
+		{"tile naming a missing city", func(g *game) { g.Map[0][0].CityID = g.NextCityID + 5 }},
+		{"tile owned by no player", func(g *game) { g.Map[0][0].OwnerID = len(g.Players) }},
+		{"city filed under another ID", func(g *game) {
+			p := g.Players[0]
+			c := p.sortedCities()[0]
+			delete(p.Cities, c.ID)
+			p.Cities[c.ID+1] = c
+		}},
+		{"short vote tally", func(g *game) { g.VoteTally = []int{1} }},
+		{"ballot for no player", func(g *game) { g.Ballots[0] = 9 }},
+	}
+	dir := t.TempDir()
+	for _, tc := range cases {
+		g := newTestGame(t, aiSettings(2), 1)
+		tc.corrupt(g)
+		path := filepath.Join(dir, "broken.json")
+		if err := g.saveGame(path); err != nil {
+			t.Fatalf("%s: saveGame: %v", tc.name, err)
+		}
+		_, err := loadGame(path)
+		expectCode(t, tc.name, err, "CORRUPT_SAVE")
+	}
+}
+
+func TestFillFlagDefaults(t *testing.T) {
+	settings := defaultSettings()
+	settings.Players, settings.AIPlayers = 6, defaultSettings().AIPlayers
+	if got := fillFlagDefaults(settings, []string{"players"}); got.AIPlayers != 5 {
+		t.Errorf("-players 6 alone gives %d AI players, want 5", got.AIPlayers)
+	}
+	settings.AIPlayers = 2
+	if got := fillFlagDefaults(settings, []string{"ai", "players"}); got.AIPlayers != 2 {
+		t.Errorf("-ai 2 -players 6 gives %d AI players, want 2", got.AIPlayers)
+	}
+}
+
+func TestCheckLoadFlags(t *testing.T) {
+	if err := checkLoadFlags([]string{"load"}); err != nil {
+		t.Errorf("-load alone: %v", err)
+	}
+	if err := checkLoadFlags([]string{"load", "seed"}); err == nil {
+		t.Error("-seed with -load was accepted")
+	}
+	if err := checkLoadFlags([]string{"ai", "load"}); err == nil {
+		t.Error("-ai with -load was accepted")
+	}
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	current := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	unit := current.sortedUnits()[0]
+
+	_, err := g.MoveUnit(other.ID, other.sortedUnits()[0].ID, 0, 0)
+	expectCode(t, "move out of turn", err, "NOT_YOUR_TURN")
+	_, err = g.MoveUnit(current.ID, -5, 0, 0)
+	expectCode(t, "move unknown unit", err, "UNIT_NOT_FOUND")
+	_, err = g.MoveUnit(current.ID, unit.ID, -1, 0)
+	expectCode(t, "move off the map", err, "OUT_OF_BOUNDS")
+	_, err = g.MoveUnit(99, unit.ID, 0, 0)
+	expectCode(t, "unknown player", err, "PLAYER_NOT_FOUND")
+
+	err = g.SetResearch(current.ID, techCount)
+	expectCode(t, "research unknown tech", err, "INVALID_TECH")
+	err = g.SetResearch(current.ID, techAgriculture)
+	expectCode(t, "research known tech", err, "TECH_KNOWN")
+	err = g.SetResearch(current.ID, techIndustrialization)
+	expectCode(t, "research locked tech", err, "TECH_LOCKED")
+
+	g.Running = false
+	_, err = g.EndTurn(current.ID)
+	expectCode(t, "end turn after game over", err, "GAME_OVER")
+}
+
+// freeNeighbour is a passable, empty tile next to (x, y).
+func freeNeighbour(t *testing.T, g *game, x, y int) (int, int) {
+	t.Helper()
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {-1, -1}, {1, -1}, {-1, 1}} {
+		nx, ny := x+d[0], y+d[1]
+		if g.isValidTile(nx, ny) && g.Map[ny][nx].UnitID == -1 && g.Map[ny][nx].CityID == -1 {
+			return nx, ny
+		}
+	}
+	t.Fatalf("no free tile next to (%d,%d)", x, y)
+	return 0, 0
+}
+
+// startingUnit is the current player's first unit of the given type.
+func startingUnit(t *testing.T, g *game, ut unitType) (*player, *unit) {
+	t.Helper()
+	p := g.Players[g.CurrentPlayerIndex]
+	for _, u := range p.sortedUnits() {
+		if u.Type == ut {
+			return p, u
+		}
+	}
+	t.Fatalf("%s has no %s", p.Name, unitToString(ut))
+	return nil, nil
+}
+
+func TestMoveUnit(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p, warrior := startingUnit(t, g, unitWarrior)
+	fromX, fromY := warrior.X, warrior.Y
+	x, y := freeNeighbour(t, g, fromX, fromY)
+
+	result, err := g.MoveUnit(p.ID, warrior.ID, x, y)
+	if err != nil {
+		t.Fatalf("MoveUnit: %v", err)
+	}
+	if result.UnitID != warrior.ID || result.FromX != fromX || result.FromY != fromY || result.X != x || result.Y != y {
+		t.Errorf("MoveUnit result = %+v, want unit %d from (%d,%d) to (%d,%d)", result, warrior.ID, fromX, fromY, x, y)
+	}
+	if g.Map[y][x].UnitID != warrior.ID || g.Map[fromY][fromX].UnitID != -1 {
+		t.Error("the map doesn't show the warrior on its new tile")
+	}
+
+	_, settler := startingUnit(t, g, unitSettler)
+	_, err = g.MoveUnit(p.ID, settler.ID, x, y)
+	expectCode(t, "move onto own unit", err, "NO_PATH")
+}
+
+func TestFoundCity(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p, settler := startingUnit(t, g, unitSettler)
+	_, warrior := startingUnit(t, g, unitWarrior)
+
+	_, err := g.FoundCity(p.ID, settler.ID, "Second City")
+	expectCode(t, "found on the capital", err, "CITY_EXISTS")
+	_, err = g.FoundCity(p.ID, warrior.ID, "Second City")
+	expectCode(t, "found with a warrior", err, "NOT_SETTLER")
+
+	x, y := freeNeighbour(t, g, settler.X, settler.Y)
+	if _, err := g.MoveUnit(p.ID, settler.ID, x, y); err != nil {
+		t.Fatalf("MoveUnit: %v", err)
+	}
+	_, err = g.FoundCity(p.ID, settler.ID, "No")
+	expectCode(t, "found with a short name", err, "INVALID_NAME")
+
+	cities := p.CityCount
+	c, err := g.FoundCity(p.ID, settler.ID, "Second City")
+	if err != nil {
+		t.Fatalf("FoundCity: %v", err)
+	}
+	if c.Name != "Second City" || c.OwnerID != p.ID || c.X != x || c.Y != y {
+		t.Errorf("FoundCity = %+v, want Second City of player %d at (%d,%d)", c, p.ID, x, y)
+	}
+	if p.CityCount != cities+1 || p.Cities[c.ID] != c {
+		t.Error("the new city isn't among the player's cities")
+	}
+	if _, exists := p.Units[settler.ID]; exists || g.Map[y][x].UnitID != -1 {
+		t.Error("the settler wasn't used up")
+	}
+	if g.Map[y][x].CityID != c.ID || g.Map[y][x].OwnerID != p.ID {
+		t.Error("the city's tile doesn't belong to it")
+	}
+}
+
+func TestEnqueueProduction(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p := g.Players[g.CurrentPlayerIndex]
+	capital := p.sortedCities()[0]
+
+	item, err := g.EnqueueProduction(p.ID, capital.ID, productionUnit, int(unitWarrior))
+	if err != nil {
+		t.Fatalf("EnqueueProduction: %v", err)
+	}
+	if item.Name != "Warrior" || item.TotalCost != g.getUnitCost(unitWarrior) || item.Progress != 0 {
+		t.Errorf("EnqueueProduction = %+v, want an unstarted Warrior", item)
+	}
+	if len(capital.ProductionQueue) != 1 || capital.ProductionQueue[0] != item {
+		t.Errorf("queue = %+v, want just the Warrior", capital.ProductionQueue)
+	}
+
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionBuilding, int(buildingMonument))
+	if err != nil {
+		t.Fatalf("EnqueueProduction: %v", err)
+	}
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionBuilding, int(buildingMonument))
+	expectCode(t, "queue a building twice", err, "BUILDING_EXISTS")
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionUnit, int(unitTank))
+	expectCode(t, "queue a tank in 4000 BC", err, "ITEM_LOCKED")
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionUnit, -1)
+	expectCode(t, "queue an unknown unit", err, "INVALID_UNIT")
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionBuilding, -1)
+	expectCode(t, "queue an unknown building", err, "INVALID_BUILDING")
+	_, err = g.EnqueueProduction(p.ID, -1, productionUnit, int(unitWarrior))
+	expectCode(t, "queue in an unknown city", err, "CITY_NOT_FOUND")
+
+	other := g.Players[1-g.CurrentPlayerIndex]
+	_, err = g.EnqueueProduction(other.ID, other.sortedCities()[0].ID, productionUnit, int(unitWarrior))
+	expectCode(t, "queue out of turn", err, "NOT_YOUR_TURN")
+}
+
+func TestEndTurn(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	first := g.Players[g.CurrentPlayerIndex]
+	second := g.Players[1-g.CurrentPlayerIndex]
+	capital := first.sortedCities()[0]
+	if _, err := g.EnqueueProduction(first.ID, capital.ID, productionUnit, int(unitWarrior)); err != nil {
+		t.Fatalf("EnqueueProduction: %v", err)
+	}
+	year := g.Year
+
+	_, err := g.EndTurn(second.ID)
+	expectCode(t, "end someone else's turn", err, "NOT_YOUR_TURN")
+
+	result, err := g.EndTurn(first.ID)
+	if err != nil {
+		t.Fatalf("EndTurn: %v", err)
+	}
+	if result.NextPlayerID != second.ID || result.YearEnded || result.Year != year || result.GameOver {
+		t.Errorf("first EndTurn = %+v, want %s to move in the same year", result, second.Name)
+	}
+
+	units := first.UnitCount
+	for turn := 0; turn < 200 && first.UnitCount == units; turn++ {
+		result, err = g.EndTurn(g.Players[g.CurrentPlayerIndex].ID)
+		if err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+		if g.CurrentPlayerIndex == first.ID && (!result.YearEnded || result.Year != year+g.Settings.YearsPerTurn) {
+			t.Errorf("EndTurn = %+v, want the year to end %d years later once everyone moved", result, g.Settings.YearsPerTurn)
+		}
+		year = result.Year
+	}
+	if first.UnitCount != units+1 {
+		t.Errorf("%s has %d units, want the queued Warrior delivered", first.Name, first.UnitCount)
+	}
+	if len(capital.ProductionQueue) != 0 {
+		t.Errorf("queue = %+v, want it empty once the Warrior is built", capital.ProductionQueue)
+	}
+}
+
+// clearBoard turns the map into empty plains with no cities or units, so a
+// test can set up exactly the position it needs.
+func clearBoard(g *game) {
+	for y := range g.Map {
+		for x := range g.Map[y] {
+			g.Map[y][x] = tile{Terrain: terrainPlains, UnitID: -1, CityID: -1, OwnerID: -1}
+		}
+	}
+	for _, p := range g.Players {
+		p.Units, p.UnitCount = make(map[int]*unit), 0
+		p.Cities, p.CityCount = make(map[int]*city), 0
+		p.Memory = nil
+	}
+}
+
+// spawnUnit puts a new unit of the given type on (x, y).
+func spawnUnit(t *testing.T, g *game, owner *player, ut unitType, x, y int) *unit {
+	t.Helper()
+	u, err := g.createUnit(ut, owner)
+	if err != nil {
+		t.Fatalf("createUnit: %v", err)
+	}
+	u.X, u.Y = x, y
+	owner.Units[u.ID] = u
+	owner.UnitCount++
+	g.Map[y][x].UnitID = u.ID
+	return u
+}
+
+func TestDefenseStrength(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	warrior := spawnUnit(t, g, g.Players[1], unitWarrior, 5, 5)
+
+	if got, want := g.defenseStrength(warrior), warrior.Strength*100; got != want {
+		t.Errorf("defense on plains = %d, want %d", got, want)
+	}
+	g.Map[5][5].Terrain = terrainHills
+	if got, want := g.defenseStrength(warrior), warrior.Strength*(100+terrainDefenseBonus[terrainHills]); got != want {
+		t.Errorf("defense on hills = %d, want %d", got, want)
+	}
+	g.Map[5][5].CityID = 0
+	if got, want := g.defenseStrength(warrior), warrior.Strength*(100+terrainDefenseBonus[terrainHills]+cityDefenseBonus); got != want {
+		t.Errorf("defense in a hill city = %d, want %d", got, want)
+	}
+	if got, want := g.attackStrength(warrior, g.Map[warrior.Y][warrior.X].Terrain), warrior.Strength*100; got != want {
+		t.Errorf("attack = %d, want %d ignoring terrain", got, want)
+	}
+}
+
+func TestAttack(t *testing.T) {
+	for seed := uint64(1); seed <= 20; seed++ {
+		g := newTestGame(t, aiSettings(2), seed)
+		clearBoard(g)
+		attacker := spawnUnit(t, g, g.Players[0], unitSwordsman, 5, 5)
+		defender := spawnUnit(t, g, g.Players[1], unitWarrior, 6, 5)
+
+		attack, defense := g.attackStrength(attacker, g.Map[defender.Y][defender.X].Terrain), g.defenseStrength(defender)
+		result, err := g.attack(attacker, defender)
+		if err != nil {
+			t.Fatalf("seed %d: attack: %v", seed, err)
+		}
+		if result.Rounds == 0 || result.AttackStrength != attack || result.DefenseStrength != defense {
+			t.Errorf("seed %d: result = %+v", seed, result)
+		}
+		if result.AttackerHealth != attacker.Health || result.DefenderHealth != defender.Health {
+			t.Errorf("seed %d: result reports health %d/%d, units have %d/%d", seed,
+				result.AttackerHealth, result.DefenderHealth, attacker.Health, defender.Health)
+		}
+		if result.AttackerDestroyed == result.DefenderDestroyed {
+			t.Fatalf("seed %d: want exactly one unit destroyed, got %+v", seed, result)
+		}
+		if attacker.Movement != 0 {
+			t.Errorf("seed %d: attacker has %d movement left after attacking", seed, attacker.Movement)
+		}
+
+		loser, loserOwner, winner := defender, g.Players[1], attacker
+		if result.AttackerDestroyed {
+			loser, loserOwner, winner = attacker, g.Players[0], defender
+		}
+		if loser.Health != 0 || winner.Health == 0 {
+			t.Errorf("seed %d: loser has %d health, winner %d", seed, loser.Health, winner.Health)
+		}
+		if _, exists := loserOwner.Units[loser.ID]; exists || loserOwner.UnitCount != 0 {
+			t.Errorf("seed %d: the destroyed unit is still in its owner's army", seed)
+		}
+		if g.Map[loser.Y][loser.X].UnitID != -1 || g.Map[winner.Y][winner.X].UnitID != winner.ID {
+			t.Errorf("seed %d: the map still shows the destroyed unit", seed)
+		}
+	}
+}
+
+func TestStrongerSideUsuallyWins(t *testing.T) {
+	wins := map[terrainType]int{}
+	for _, terrain := range []terrainType{terrainPlains, terrainHills} {
+		for seed := uint64(1); seed <= 50; seed++ {
+			g := newTestGame(t, aiSettings(2), seed)
+			clearBoard(g)
+			g.Map[5][6].Terrain = terrain
+			attacker := spawnUnit(t, g, g.Players[0], unitWarrior, 5, 5)
+			defender := spawnUnit(t, g, g.Players[1], unitWarrior, 6, 5)
+			if result, _ := g.attack(attacker, defender); result.DefenderDestroyed {
+				wins[terrain]++
+			}
+		}
+	}
+	if wins[terrainPlains] < 15 || wins[terrainPlains] > 35 {
+		t.Errorf("equal warriors on plains: attacker won %d of 50", wins[terrainPlains])
+	}
+	if wins[terrainHills] >= wins[terrainPlains] {
+		t.Errorf("attacker won %d of 50 against hills, %d against plains; want hills to help the defender", wins[terrainHills], wins[terrainPlains])
+	}
+
+	tankWins := 0
+	for seed := uint64(1); seed <= 20; seed++ {
+		g := newTestGame(t, aiSettings(2), seed)
+		clearBoard(g)
+		tank := spawnUnit(t, g, g.Players[0], unitTank, 5, 5)
+		warrior := spawnUnit(t, g, g.Players[1], unitWarrior, 6, 5)
+		if result, _ := g.attack(tank, warrior); result.DefenderDestroyed {
+			tankWins++
+		}
+	}
+	if tankWins < 19 {
+		t.Errorf("a tank beat a warrior %d times out of 20", tankWins)
+	}
+}
+
+func TestMoveUnitAttacks(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 5)
+	clearBoard(g)
+	current := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	attacker := spawnUnit(t, g, current, unitTank, 5, 5)
+	settler := spawnUnit(t, g, current, unitSettler, 5, 6)
+	defender := spawnUnit(t, g, other, unitWarrior, 6, 5)
+	spawnUnit(t, g, other, unitWarrior, 8, 8)
+	g.setStance(current.ID, other.ID, stanceWar)
+
+	_, err := g.MoveUnit(current.ID, settler.ID, 6, 5)
+	expectCode(t, "attack with a settler", err, "CANNOT_ATTACK")
+	_, err = g.MoveUnit(current.ID, attacker.ID, 8, 8)
+	expectCode(t, "attack from afar", err, "NOT_ADJACENT")
+
+	result, err := g.MoveUnit(current.ID, attacker.ID, defender.X, defender.Y)
+	if err != nil {
+		t.Fatalf("MoveUnit: %v", err)
+	}
+	if result.Combat == nil {
+		t.Fatal("moving onto an enemy didn't fight")
+	}
+	if result.X != 5 || result.Y != 5 {
+		t.Errorf("the attacker ended on (%d,%d), want it to stay on (5,5)", result.X, result.Y)
+	}
+	if result.Combat.DefenderDestroyed && g.Map[5][6].UnitID != -1 {
+		t.Error("the defender's tile isn't empty after it was destroyed")
+	}
+}
+
+func TestSetResearchNeedsPrerequisites(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	p := g.Players[g.CurrentPlayerIndex]
+
+	err := g.SetResearch(p.ID, techPhilosophy)
+	expectCode(t, "research Philosophy first", err, "TECH_LOCKED")
+
+	// Philosophy needs both Writing and Mathematics.
+	p.Techs[techPottery], p.Techs[techWriting] = true, true
+	err = g.SetResearch(p.ID, techPhilosophy)
+	expectCode(t, "research Philosophy without Mathematics", err, "TECH_LOCKED")
+	p.Techs[techMathematics] = true
+	if err := g.SetResearch(p.ID, techPhilosophy); err != nil {
+		t.Fatalf("SetResearch with every prerequisite: %v", err)
+	}
+	if p.Researching != techPhilosophy {
+		t.Errorf("researching %s, want Philosophy", techToString(p.Researching))
+	}
+}
+
+func TestResearchAccumulates(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	p := g.Players[g.CurrentPlayerIndex]
+	if err := g.SetResearch(p.ID, techPottery); err != nil {
+		t.Fatalf("SetResearch: %v", err)
+	}
+	perTurn := g.researchOutput(p)
+	if perTurn <= 0 {
+		t.Fatalf("research output = %d, want some", perTurn)
+	}
+	cost := g.Settings.techCost(techPottery)
+
+	turns := 0
+	for !p.Techs[techPottery] {
+		if p.ResearchProgress != turns*perTurn {
+			t.Fatalf("after %d turns progress = %d, want %d", turns, p.ResearchProgress, turns*perTurn)
+		}
+		g.advanceResearch(p)
+		turns++
+	}
+	if want := (cost + perTurn - 1) / perTurn; turns != want {
+		t.Errorf("Pottery took %d turns at %d a turn, want %d", turns, perTurn, want)
+	}
+	if want := turns*perTurn - cost; p.ResearchProgress != want {
+		t.Errorf("progress after Pottery = %d, want the %d left over", p.ResearchProgress, want)
+	}
+	if p.Techs[p.Researching] || !p.canResearch(p.Researching) {
+		t.Errorf("moved on to %s, want an unknown tech whose prerequisites are met", techToString(p.Researching))
+	}
+
+	capital := p.sortedCities()[0]
+	capital.Buildings = append(capital.Buildings, buildingLibrary)
+	if got, want := g.researchOutput(p), perTurn*(100+buildingEffects[buildingLibrary].ResearchBonus)/100; got != want {
+		t.Errorf("research with a Library = %d, want %d", got, want)
+	}
+}
+
+func TestTechGatesProduction(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	p := g.Players[g.CurrentPlayerIndex]
+	capital := p.sortedCities()[0]
+
+	_, err := g.EnqueueProduction(p.ID, capital.ID, productionBuilding, int(buildingGranary))
+	expectCode(t, "queue a granary without Pottery", err, "ITEM_LOCKED")
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionUnit, int(unitSwordsman))
+	expectCode(t, "queue a swordsman without Mathematics", err, "ITEM_LOCKED")
+	for _, u := range g.availableUnits(p) {
+		if unitRequiredTech[u] != techAgriculture {
+			t.Errorf("%s is available without %s", unitToString(u), techToString(unitRequiredTech[u]))
+		}
+	}
+
+	p.Techs[techPottery] = true
+	if _, err := g.EnqueueProduction(p.ID, capital.ID, productionBuilding, int(buildingGranary)); err != nil {
+		t.Errorf("queue a granary with Pottery: %v", err)
+	}
+	p.Techs[techWriting], p.Techs[techMathematics] = true, true
+	g.Map[capital.Y][capital.X].Resource = resourceIron
+	if _, err := g.EnqueueProduction(p.ID, capital.ID, productionUnit, int(unitSwordsman)); err != nil {
+		t.Errorf("queue a swordsman with Mathematics: %v", err)
+	}
+}
+
+// addCity founds a city for owner on (x, y) without going through a settler.
+// keepPlayers gives every player a city along the top row, away from the
+// action, so a cleared board doesn't eliminate them when turns end.
+func keepPlayers(g *game) {
+	for _, p := range g.Players {
+		addCity(g, p, 10+4*p.ID, 0)
+	}
+}
+
+func addCity(g *game, owner *player, x, y int) *city {
+	c := &city{ID: g.NextCityID, Name: "Test City", Population: baseCityPopulation, OwnerID: owner.ID, X: x, Y: y}
+	g.NextCityID++
+	owner.Cities[c.ID] = c
+	owner.CityCount++
+	g.Map[y][x].CityID, g.Map[y][x].OwnerID = c.ID, owner.ID
+	return c
+}
+
+func TestBuildingEffects(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[1]
+	c := addCity(g, owner, 5, 5)
+	defender := spawnUnit(t, g, owner, unitWarrior, 5, 5)
+
+	plain := g.defenseStrength(defender)
+	production := g.cityProduction(c)
+	c.Buildings = append(c.Buildings, buildingWalls, buildingFactory, buildingMonument, buildingTemple)
+
+	if got, want := g.defenseStrength(defender), plain+defender.Strength*buildingEffects[buildingWalls].DefenseBonus; got != want {
+		t.Errorf("defense behind Walls = %d, want %d", got, want)
+	}
+	if got, want := g.cityProduction(c), production*(100+buildingEffects[buildingFactory].ProductionBonus)/100; got != want {
+		t.Errorf("production with a Factory = %d, want %d", got, want)
+	}
+	effects := c.effects()
+	if want := buildingEffects[buildingMonument].Culture + buildingEffects[buildingTemple].Culture; effects.Culture != want {
+		t.Errorf("culture from Monument and Temple = %d, want %d", effects.Culture, want)
+	}
+	if want := buildingEffects[buildingMonument].Happiness + buildingEffects[buildingTemple].Happiness; effects.Happiness != want {
+		t.Errorf("citizens made content by Monument and Temple = %d, want %d", effects.Happiness, want)
+	}
+
+	culture := c.Culture
+	if err := g.updatePlayer(owner); err != nil {
+		t.Fatalf("updatePlayer: %v", err)
+	}
+	if c.Culture != culture+effects.Culture {
+		t.Errorf("culture after a turn = %d, want %d", c.Culture, culture+effects.Culture)
+	}
+}
+
+func TestCityGrowth(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	g.assignWorkedTiles()
+
+	// A size 1 city on plains eats 2 of the 5 food from its center and one
+	// worked tile.
+	surplus := g.foodSurplus(c)
+	if want := terrainYields[terrainPlains].Food*2 + cityCenterFoodBonus - foodPerCitizen; surplus != want {
+		t.Fatalf("food surplus = %d, want %d", surplus, want)
+	}
+	turns := 0
+	for c.Population == 1 && turns < 100 {
+		g.growCity(c, owner)
+		turns++
+	}
+	if want := (foodBox(1) + surplus - 1) / surplus; turns != want {
+		t.Errorf("the city took %d turns to grow, want %d", turns, want)
+	}
+	if want := turns*surplus - foodBox(1); c.Population != 2 || c.Food != want {
+		t.Errorf("after growing: size %d with %d food, want size 2 with %d", c.Population, c.Food, want)
+	}
+}
+
+func TestGranaryKeepsFood(t *testing.T) {
+	for _, granary := range []bool{false, true} {
+		g := newTestGame(t, aiSettings(2), 1)
+		clearBoard(g)
+		owner := g.Players[0]
+		c := addCity(g, owner, 5, 5)
+		if granary {
+			c.Buildings = append(c.Buildings, buildingGranary)
+		}
+		g.assignWorkedTiles()
+		c.Food = foodBox(1) - 1
+
+		want := g.foodSurplus(c) - 1
+		g.growCity(c, owner)
+		if granary {
+			want += foodBox(1) * buildingEffects[buildingGranary].FoodKept / 100
+		}
+		if c.Population != 2 || c.Food != want {
+			t.Errorf("granary %v: size %d with %d food after growing, want size 2 with %d", granary, c.Population, c.Food, want)
+		}
+	}
+}
+
+func TestStarvation(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	for y := range g.Map {
+		for x := range g.Map[y] {
+			g.Map[y][x].Terrain = terrainDesert
+		}
+	}
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Population, c.Food = 3, 4
+	g.assignWorkedTiles()
+
+	if surplus := g.foodSurplus(c); surplus >= 0 {
+		t.Fatalf("a size 3 desert city has a food surplus of %d", surplus)
+	}
+	g.growCity(c, owner)
+	if c.Population != 2 || c.Food != 0 {
+		t.Errorf("after a famine: size %d with %d food, want size 2 with none", c.Population, c.Food)
+	}
+	c.Population = 1
+	g.assignWorkedTiles()
+	for i := 0; i < 5; i++ {
+		g.growCity(c, owner)
+	}
+	if c.Population != 1 {
+		t.Errorf("a starving city shrank to size %d", c.Population)
+	}
+}
+
+func TestProductionOverflow(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	g.assignWorkedTiles()
+	shields := g.cityProduction(c)
+	if shields <= 0 {
+		t.Fatalf("production = %d, want some", shields)
+	}
+
+	for _, b := range []buildingType{buildingMonument, buildingBarracks} {
+		if _, err := g.addToProductionQueue(c, productionBuilding, int(b)); err != nil {
+			t.Fatalf("addToProductionQueue: %v", err)
+		}
+	}
+	monument := c.ProductionQueue[0].TotalCost
+	turns := (monument + shields - 1) / shields
+	for i := 0; i < turns; i++ {
+		if err := g.advanceProduction(c, owner); err != nil {
+			t.Fatalf("advanceProduction: %v", err)
+		}
+	}
+	if !c.hasBuilding(buildingMonument) {
+		t.Fatalf("no Monument after %d turns at %d shields", turns, shields)
+	}
+	if len(c.ProductionQueue) != 1 || c.ProductionQueue[0].Progress != turns*shields-monument {
+		t.Errorf("queue = %+v, want the Barracks started with the %d overflow shields", c.ProductionQueue, turns*shields-monument)
+	}
+
+	// With nothing queued the overflow waits for the next item.
+	c.ProductionQueue[0].Progress = c.ProductionQueue[0].TotalCost - 1
+	if err := g.advanceProduction(c, owner); err != nil {
+		t.Fatalf("advanceProduction: %v", err)
+	}
+	if len(c.ProductionQueue) != 0 || c.Overflow != shields-1 {
+		t.Fatalf("queue %+v with %d overflow, want it empty with %d", c.ProductionQueue, c.Overflow, shields-1)
+	}
+	item, err := g.addToProductionQueue(c, productionUnit, int(unitWarrior))
+	if err != nil {
+		t.Fatalf("addToProductionQueue: %v", err)
+	}
+	if item.Progress != shields-1 || c.Overflow != 0 {
+		t.Errorf("the next item started at %d with %d overflow left, want %d and none", item.Progress, c.Overflow, shields-1)
+	}
+}
+
+func TestUnitWaitsForRoom(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner, other := g.Players[0], g.Players[1]
+	c := addCity(g, owner, 5, 5)
+	g.assignWorkedTiles()
+	spawnUnit(t, g, owner, unitWarrior, 5, 5)
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		spawnUnit(t, g, other, unitWarrior, 5+d[0], 5+d[1])
+	}
+	if _, err := g.addToProductionQueue(c, productionUnit, int(unitWarrior)); err != nil {
+		t.Fatalf("addToProductionQueue: %v", err)
+	}
+	c.ProductionQueue[0].Progress = c.ProductionQueue[0].TotalCost
+
+	units := owner.UnitCount
+	if err := g.advanceProduction(c, owner); err != nil {
+		t.Fatalf("advanceProduction with no room: %v", err)
+	}
+	if owner.UnitCount != units || len(c.ProductionQueue) != 1 {
+		t.Fatalf("%d units and queue %+v, want the Warrior waiting", owner.UnitCount, c.ProductionQueue)
+	}
+
+	g.removeUnit(owner.sortedUnits()[0])
+	if err := g.advanceProduction(c, owner); err != nil {
+		t.Fatalf("advanceProduction: %v", err)
+	}
+	if owner.UnitCount != units || len(c.ProductionQueue) != 0 || g.Map[5][5].UnitID == -1 {
+		t.Errorf("%d units and queue %+v, want the Warrior delivered into the city", owner.UnitCount, c.ProductionQueue)
+	}
+}
+
+func TestGoldIncomeAndUpkeep(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Buildings = append(c.Buildings, buildingMonument, buildingUniversity)
+	g.assignWorkedTiles()
+	for i := 0; i < freeUnitsPerCity+3; i++ {
+		spawnUnit(t, g, owner, unitWarrior, i, 0)
+	}
+
+	if want := g.cityYield(c).Trade + c.Population*taxPerCitizen; g.goldIncome(owner) != want {
+		t.Errorf("income = %d, want %d", g.goldIncome(owner), want)
+	}
+	want := buildingEffects[buildingMonument].Upkeep + buildingEffects[buildingUniversity].Upkeep + 3*unitUpkeep
+	if got := g.goldUpkeep(owner); got != want {
+		t.Errorf("upkeep = %d, want %d for two buildings and 3 units over the free ones", got, want)
+	}
+
+	owner.Gold = 100
+	income, upkeep := g.goldIncome(owner), g.goldUpkeep(owner)
+	g.collectGold(owner)
+	if owner.Gold != 100+income-upkeep || owner.Bankrupt {
+		t.Errorf("gold = %d (bankrupt %v), want %d", owner.Gold, owner.Bankrupt, 100+income-upkeep)
+	}
+}
+
+func TestBankruptcySellsBuildings(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Buildings = append(c.Buildings, buildingMonument, buildingFactory)
+	g.assignWorkedTiles()
+	owner.Gold = g.goldUpkeep(owner) - g.goldIncome(owner) - 1
+
+	g.collectGold(owner)
+	if owner.Bankrupt {
+		t.Error("still bankrupt after the sale paid the upkeep")
+	}
+	if c.hasBuilding(buildingFactory) || !c.hasBuilding(buildingMonument) {
+		t.Errorf("buildings = %v, want the Factory sold first for its higher upkeep", c.Buildings)
+	}
+	if want := g.getBuildingCost(buildingFactory)*buildingSellPercent/100 - 1; owner.Gold != want {
+		t.Errorf("gold after selling the Factory = %d, want %d", owner.Gold, want)
+	}
+}
+
+func TestBuyProduction(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	p := g.Players[g.CurrentPlayerIndex]
+	capital := p.sortedCities()[0]
+
+	_, err := g.BuyProduction(p.ID, capital.ID)
+	expectCode(t, "buy with an empty queue", err, "NOTHING_TO_BUY")
+
+	item, err := g.EnqueueProduction(p.ID, capital.ID, productionUnit, int(unitWarrior))
+	if err != nil {
+		t.Fatalf("EnqueueProduction: %v", err)
+	}
+	price := buyPrice(item)
+	p.Gold = price - 1
+	_, err = g.BuyProduction(p.ID, capital.ID)
+	expectCode(t, "buy without the gold", err, "NOT_ENOUGH_GOLD")
+
+	p.Gold = price + 5
+	paid, err := g.BuyProduction(p.ID, capital.ID)
+	if err != nil {
+		t.Fatalf("BuyProduction: %v", err)
+	}
+	if paid != price || p.Gold != 5 || capital.ProductionQueue[0].Progress != item.TotalCost {
+		t.Errorf("paid %d leaving %d gold and progress %d, want %d leaving 5 and the item finished",
+			paid, p.Gold, capital.ProductionQueue[0].Progress, price)
+	}
+	_, err = g.BuyProduction(p.ID, capital.ID)
+	expectCode(t, "buy a finished item", err, "NOTHING_TO_BUY")
+
+	if half := buyPrice(productionItem{TotalCost: 100, Progress: 50}); 2*half >= buyPrice(productionItem{TotalCost: 100}) {
+		t.Errorf("buying half an item costs %d, want less than half of buying it all", half)
+	}
+}
+
+func TestCityMood(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Population = contentCitizens + 3
+
+	if content, unhappy := g.cityMood(c, owner); content != contentCitizens || unhappy != 3 {
+		t.Errorf("mood = %d content, %d unhappy, want %d and 3", content, unhappy, contentCitizens)
+	}
+	c.Buildings = append(c.Buildings, buildingTemple)
+	if _, unhappy := g.cityMood(c, owner); unhappy != 3-buildingEffects[buildingTemple].Happiness {
+		t.Errorf("%d unhappy with a Temple, want %d", unhappy, 3-buildingEffects[buildingTemple].Happiness)
+	}
+	owner.WarWeariness = 2 * warWearinessPerUnhappy
+	if _, unhappy := g.cityMood(c, owner); unhappy != 3-buildingEffects[buildingTemple].Happiness+2 {
+		t.Errorf("%d unhappy with war weariness, want 2 more", unhappy)
+	}
+}
+
+func TestRiots(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Population = 2 * contentCitizens
+	if _, err := g.addToProductionQueue(c, productionBuilding, int(buildingMonument)); err != nil {
+		t.Fatalf("addToProductionQueue: %v", err)
+	}
+	c.ProductionQueue[0].Progress = 20
+
+	for turn := 1; turn < riotTurns; turn++ {
+		g.updateHappiness(owner)
+		if c.UnrestTurns != turn || c.ProductionQueue[0].Progress != 20 {
+			t.Fatalf("turn %d: %d turns of unrest and progress %d, want %d and 20", turn, c.UnrestTurns, c.ProductionQueue[0].Progress, turn)
+		}
+	}
+	g.updateHappiness(owner)
+	if c.ProductionQueue[0].Progress != 10 || c.Population != 2*contentCitizens-1 {
+		t.Errorf("after riots: progress %d and size %d, want 10 and %d", c.ProductionQueue[0].Progress, c.Population, 2*contentCitizens-1)
+	}
+	if c.Unhappy == 0 || owner.Happiness >= 100 {
+		t.Errorf("%d unhappy and %d%% content, want the riot recorded", c.Unhappy, owner.Happiness)
+	}
+}
+
+func TestRevolt(t *testing.T) {
+	for _, happier := range []bool{false, true} {
+		g := newTestGame(t, aiSettings(2), 1)
+		clearBoard(g)
+		owner, neighbour := g.Players[0], g.Players[1]
+		c := addCity(g, owner, 5, 5)
+		addCity(g, neighbour, 5+revoltRadius, 5)
+		garrison := spawnUnit(t, g, owner, unitWarrior, 5, 5)
+		c.Population, c.UnrestTurns = 3*contentCitizens, revoltTurns-1
+		owner.Happiness, neighbour.Happiness = 50, 50
+		if happier {
+			neighbour.Happiness = 90
+		}
+
+		g.updateHappiness(owner)
+		revolted := c.OwnerID == neighbour.ID
+		if revolted != happier {
+			t.Errorf("neighbour happier %v: the city revolted %v", happier, revolted)
+			continue
+		}
+		if !revolted {
+			continue
+		}
+		if _, exists := neighbour.Cities[c.ID]; !exists || owner.CityCount != 0 || g.Map[5][5].OwnerID != neighbour.ID {
+			t.Error("the revolting city didn't change hands")
+		}
+		if garrison.OwnerID != neighbour.ID || neighbour.Units[garrison.ID] != garrison || owner.UnitCount != 0 {
+			t.Error("the garrison didn't defect with its city")
+		}
+	}
+}
+
+func TestPeaceBlocksAttacks(t *testing.T) {
+	for _, stance := range []diplomaticStance{stancePeace, stanceCeasefire} {
+		g := newTestGame(t, aiSettings(2), 1)
+		clearBoard(g)
+		current := g.Players[g.CurrentPlayerIndex]
+		other := g.Players[1-g.CurrentPlayerIndex]
+		attacker := spawnUnit(t, g, current, unitWarrior, 5, 5)
+		defender := spawnUnit(t, g, other, unitWarrior, 6, 5)
+		g.setStance(current.ID, other.ID, stance)
+
+		_, err := g.MoveUnit(current.ID, attacker.ID, 6, 5)
+		expectCode(t, "attack during "+stanceToString(stance), err, "NOT_AT_WAR")
+		if attacker.Health != 100 || defender.Health != 100 || attacker.X != 5 {
+			t.Errorf("%s: the units fought or moved anyway", stanceToString(stance))
+		}
+		if _, found := g.findAdjacentEnemy(attacker); found {
+			t.Errorf("%s: the other civilization's unit counts as an enemy", stanceToString(stance))
+		}
+	}
+}
+
+func TestProposeTreatyFollowsRelations(t *testing.T) {
+	cases := []struct {
+		stance   diplomaticStance
+		proposal proposalType
+		relation int
+		accepted bool
+	}{
+		{stanceWar, proposeCeasefire, -50, false},
+		{stanceWar, proposeCeasefire, -30, true},
+		{stanceWar, proposePeace, 29, false},
+		{stanceWar, proposePeace, 30, true},
+		{stanceCeasefire, proposePeace, -1, false},
+		{stanceCeasefire, proposePeace, 0, true},
+	}
+	for _, tc := range cases {
+		g := newTestGame(t, aiSettings(2), 1)
+		current := g.Players[g.CurrentPlayerIndex]
+		other := g.Players[1-g.CurrentPlayerIndex]
+		g.setStance(current.ID, other.ID, tc.stance)
+		other.Relations[current.ID] = tc.relation
+		current.Relations[other.ID] = 0
+
+		accepted, err := g.ProposeTreaty(current.ID, other.ID, tc.proposal)
+		if err != nil {
+			t.Fatalf("ProposeTreaty: %v", err)
+		}
+		if accepted != tc.accepted {
+			t.Errorf("%s at relation %d: accepted %v, want %v", stanceToString(tc.stance), tc.relation, accepted, tc.accepted)
+			continue
+		}
+		want := tc.stance
+		switch {
+		case accepted && tc.proposal == proposeCeasefire:
+			want = stanceCeasefire
+		case accepted:
+			want = stancePeace
+		}
+		if g.stance(current.ID, other.ID) != want || g.stance(other.ID, current.ID) != want {
+			t.Errorf("%s at relation %d: stance is %s, want %s", stanceToString(tc.stance), tc.relation,
+				stanceToString(g.stance(current.ID, other.ID)), stanceToString(want))
+		}
+		if !accepted && current.Relations[other.ID] != -5 {
+			t.Errorf("a rejected proposer regards the other side at %d, want -5", current.Relations[other.ID])
+		}
+	}
+
+	g := newTestGame(t, aiSettings(2), 1)
+	current := g.Players[g.CurrentPlayerIndex]
+	_, err := g.ProposeTreaty(current.ID, 1-current.ID, proposeCeasefire)
+	expectCode(t, "propose a ceasefire in peacetime", err, "INVALID_PROPOSAL")
+}
+
+func TestBreakingTreatyDrifts(t *testing.T) {
+	g := newTestGame(t, aiSettings(3), 1)
+	clearBoard(g)
+	breaker := g.Players[g.CurrentPlayerIndex]
+	victim := g.Players[(g.CurrentPlayerIndex+1)%3]
+	witness := g.Players[(g.CurrentPlayerIndex+2)%3]
+	addCity(g, victim, 10, 10)
+	spawnUnit(t, g, breaker, unitWarrior, 10+borderContactRadius, 10)
+
+	if err := g.DeclareWar(breaker.ID, victim.ID); err != nil {
+		t.Fatalf("DeclareWar: %v", err)
+	}
+	if victim.Relations[breaker.ID] != -50 || witness.Relations[breaker.ID] != -10 {
+		t.Errorf("after breaking the peace: victim %d, witness %d, want -50 and -10",
+			victim.Relations[breaker.ID], witness.Relations[breaker.ID])
+	}
+	err := g.DeclareWar(breaker.ID, victim.ID)
+	expectCode(t, "declare war twice", err, "ALREADY_AT_WAR")
+
+	// Units pressing on the victim's city sour relations on both sides;
+	// civilizations out of contact warm up.
+	for i := 0; i < 5; i++ {
+		g.updateDiplomacy()
+	}
+	if victim.Relations[breaker.ID] != -60 || breaker.Relations[victim.ID] != -10 {
+		t.Errorf("after 5 years in contact: victim %d, breaker %d, want -60 and -10",
+			victim.Relations[breaker.ID], breaker.Relations[victim.ID])
+	}
+	if witness.Relations[breaker.ID] != -5 {
+		t.Errorf("after 5 years without contact the witness regards the breaker at %d, want -5", witness.Relations[breaker.ID])
+	}
+
+	g.setStance(breaker.ID, victim.ID, stanceCeasefire)
+	for i := 0; i < ceasefireTurns; i++ {
+		g.updateDiplomacy()
+	}
+	if g.stance(breaker.ID, victim.ID) != stanceWar {
+		t.Errorf("after %d years the ceasefire is still %s, want it lapsed into war", ceasefireTurns,
+			stanceToString(g.stance(breaker.ID, victim.ID)))
+	}
+}
+
+// landAdjacency counts the land tiles and the share of their neighbours
+// that are land too.
+func landAdjacency(g *game) (land int, adjacency float64) {
+	same, total := 0, 0
+	for y := range g.Map {
+		for x := range g.Map[y] {
+			if g.Map[y][x].Terrain == terrainOcean {
+				continue
+			}
+			land++
+			for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				nx, ny := g.wrap(x+d[0], y+d[1])
+				total++
+				if g.Map[ny][nx].Terrain != terrainOcean {
+					same++
+				}
+			}
+		}
+	}
+	return land, float64(same) / float64(total)
+}
+
+func TestContinentMap(t *testing.T) {
+	for seed := uint64(1); seed <= 5; seed++ {
+		g := newTestGame(t, aiSettings(2), seed)
+
+		// Scattered land would border land about as often as there is land;
+		// continents clump together.
+		land, adjacency := landAdjacency(g)
+		share := float64(land) / float64(g.Settings.MapWidth*g.Settings.MapHeight)
+		if share < landFraction-0.05 || share > landFraction+0.05 {
+			t.Errorf("seed %d: %.0f%% land, want about %.0f%%", seed, share*100, landFraction*100)
+		}
+		if adjacency < share+0.2 {
+			t.Errorf("seed %d: land borders land %.2f of the time with %.2f land, want it clumped", seed, adjacency, share)
+		}
+		for y := range g.Map {
+			for x, tile := range g.Map[y] {
+				if tile.Terrain == terrainOcean && tile.Resource != resourceNone && tile.Resource != resourceFish {
+					t.Errorf("seed %d: %s in the ocean at (%d,%d)", seed, resourceToString(tile.Resource), x, y)
+				}
+			}
+		}
+		for _, y := range []int{0, g.Settings.MapHeight - 1} {
+			for x, tile := range g.Map[y] {
+				if tile.Terrain != terrainOcean && tile.Terrain != terrainTundra && tile.Terrain != terrainMountains {
+					t.Errorf("seed %d: %s at the pole (%d,%d)", seed, terrainToString(tile.Terrain), x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestParseMapGenerator(t *testing.T) {
+	for name, want := range map[string]mapGenerator{"continents": mapGenContinents, "Random": mapGenRandom} {
+		if got, err := parseMapGenerator(name); err != nil || got != want {
+			t.Errorf("parseMapGenerator(%q) = %d, %v, want %d", name, got, err, want)
+		}
+	}
+	_, err := parseMapGenerator("islands")
+	expectCode(t, "unknown generator", err, "INVALID_INPUT")
+}
+
+func TestSettingsValidate(t *testing.T) {
+	cases := map[string]func(*gameSettings){
+		"narrow map":         func(s *gameSettings) { s.MapWidth = minMapWidth - 1 },
+		"tall map":           func(s *gameSettings) { s.MapHeight = maxMapHeight + 1 },
+		"one player":         func(s *gameSettings) { s.Players, s.AIPlayers = 1, 1 },
+		"more AIs than civs": func(s *gameSettings) { s.AIPlayers = s.Players + 1 },
+		"zero-year turns":    func(s *gameSettings) { s.YearsPerTurn = 0 },
+		"ends before start":  func(s *gameSettings) { s.EndYear = s.StartYear },
+		"no victories":       func(s *gameSettings) { s.Victories = [victoryCount]bool{} },
+	}
+	for name, change := range cases {
+		settings := defaultSettings()
+		change(&settings)
+		expectCode(t, name, settings.validate(), "INVALID_SETTINGS")
+		_, err := newGame(settings, 1)
+		expectCode(t, "newGame with "+name, err, "INVALID_SETTINGS")
+	}
+	if err := defaultSettings().validate(); err != nil {
+		t.Errorf("default settings: %v", err)
+	}
+}
+
+func TestGameFollowsSettings(t *testing.T) {
+	settings := aiSettings(3)
+	settings.MapWidth, settings.MapHeight = 30, 12
+	settings.StartYear, settings.EndYear, settings.YearsPerTurn = -1000, -900, 25
+	settings.Victories[victoryConquest] = false
+	g := newTestGame(t, settings, 4)
+
+	if len(g.Map) != 12 || len(g.Map[0]) != 30 {
+		t.Errorf("map is %dx%d, want 30x12", len(g.Map[0]), len(g.Map))
+	}
+	if g.Year != -1000 {
+		t.Errorf("the game starts in %s, want 1000 BC", formatYear(g.Year))
+	}
+
+	var result turnResult
+	turns := 0
+	for g.Running && turns < 100 {
+		var err error
+		if result, err = g.EndTurn(g.CurrentPlayerIndex); err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+		turns++
+	}
+	if !result.GameOver || g.Year != -900 {
+		t.Errorf("after %d turns: %+v in %s, want the game over in 900 BC", turns, result, formatYear(g.Year))
+	}
+	if want := 3 * 4; turns != want {
+		t.Errorf("the game lasted %d player turns, want %d: four 25-year turns for each of 3 civilizations", turns, want)
+	}
+}
+
+func TestVisibleTile(t *testing.T) {
+	g := newTestGame(t, aiSettings(3), 1)
+	clearBoard(g)
+	viewer, other := g.Players[0], g.Players[1]
+	c := addCity(g, viewer, 5, 5)
+	scout := spawnUnit(t, g, viewer, unitWarrior, 15, 5)
+	near := spawnUnit(t, g, other, unitWarrior, 5+citySightRadius, 5)
+	spawnUnit(t, g, other, unitWarrior, 5+citySightRadius+1, 5)
+	theirs := addCity(g, other, 15+unitSightRadius, 5)
+	g.updateVisibility(viewer)
+	g.CurrentPlayerIndex = viewer.ID
+
+	view, err := g.VisibleTile(viewer.ID, near.X, near.Y)
+	if err != nil {
+		t.Fatalf("VisibleTile: %v", err)
+	}
+	if !view.Explored || !view.Visible || view.Terrain != terrainPlains || view.UnitID != near.ID || view.UnitOwnerID != other.ID {
+		t.Errorf("tile in the city's sight = %+v, want it visible with the enemy warrior", view)
+	}
+	if view, _ := g.VisibleTile(viewer.ID, 5+citySightRadius+1, 5); view.Explored || view.UnitID != -1 {
+		t.Errorf("tile beyond sight = %+v, want it unexplored", view)
+	}
+	if view, _ := g.VisibleTile(viewer.ID, theirs.X, theirs.Y); view.CityID != theirs.ID || view.CityOwnerID != other.ID {
+		t.Errorf("tile next to the scout = %+v, want the foreign city", view)
+	}
+	_, err = g.VisibleTile(viewer.ID, -1, 0)
+	expectCode(t, "look off the map", err, "OUT_OF_BOUNDS")
+	_, err = g.VisibleTile(other.ID, c.X, c.Y)
+	expectCode(t, "look through a rival's eyes", err, "NOT_YOUR_TURN")
+	_, err = g.VisibleTile(len(g.Players), c.X, c.Y)
+	expectCode(t, "look as no one", err, "PLAYER_NOT_FOUND")
+
+	// Once the scout leaves, the city stays on the map as last seen, even
+	// after changing hands.
+	g.removeUnit(scout)
+	g.transferCity(theirs, g.Players[2])
+	g.updateVisibility(viewer)
+	view, _ = g.VisibleTile(viewer.ID, theirs.X, theirs.Y)
+	if !view.Explored || view.Visible || view.CityOwnerID != other.ID {
+		t.Errorf("tile out of sight = %+v, want it remembered as %s's city", view, other.Name)
+	}
+	if view, _ := g.VisibleTile(viewer.ID, c.X+1, c.Y); !view.Visible {
+		t.Errorf("tile next to the city = %+v, want it still in sight", view)
+	}
+}
+
+func TestMovementPoints(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	g.Map[5][7].Terrain = terrainForest
+	g.Map[5][9].Terrain = terrainHills
+
+	warrior := spawnUnit(t, g, p, unitWarrior, 5, 5)
+	if _, err := g.MoveUnit(p.ID, warrior.ID, 6, 5); err != nil {
+		t.Fatalf("MoveUnit onto plains: %v", err)
+	}
+	if want := warrior.maxMovement() - g.moveCost(5, 5, 6, 5); warrior.Movement != want {
+		t.Errorf("%d movement left after a plains step, want %d", warrior.Movement, want)
+	}
+	_, err := g.MoveUnit(p.ID, warrior.ID, 7, 5)
+	expectCode(t, "step into forest with one point left", err, "OUT_OF_RANGE")
+	if _, err := g.MoveUnit(p.ID, warrior.ID, 6, 6); err != nil {
+		t.Fatalf("MoveUnit with the last point: %v", err)
+	}
+	_, err = g.MoveUnit(p.ID, warrior.ID, 6, 7)
+	expectCode(t, "move with nothing left", err, "NO_MOVES_LEFT")
+
+	// Two plains tiles in one order.
+	runner := spawnUnit(t, g, p, unitWarrior, 2, 2)
+	if _, err := g.MoveUnit(p.ID, runner.ID, 4, 4); err != nil || runner.X != 4 || runner.Movement != 0 {
+		t.Errorf("diagonal two-step move: %v, at (%d,%d) with %d left", err, runner.X, runner.Y, runner.Movement)
+	}
+
+	// A unit that hasn't moved can always take one step, whatever it costs.
+	cannon := spawnUnit(t, g, p, unitCannon, 8, 5)
+	if _, err := g.MoveUnit(p.ID, cannon.ID, 9, 5); err != nil {
+		t.Errorf("a fresh cannon stepping onto hills: %v", err)
+	}
+
+	g.refreshMovement()
+	for _, u := range []*unit{warrior, runner, cannon} {
+		if u.Movement != u.maxMovement() {
+			t.Errorf("%s has %d movement after the refresh, want %d", unitToString(u.Type), u.Movement, u.maxMovement())
+		}
+	}
+}
+
+// revealMap lets p see the whole map, so paths are planned on the real
+// terrain rather than guessed through the fog.
+func revealMap(g *game, p *player) {
+	g.updateVisibility(p)
+	for y := range g.Map {
+		for x, t := range g.Map[y] {
+			p.visible[y][x] = true
+			cityOwner := -1
+			if c, err := g.findCity(t.CityID); err == nil {
+				cityOwner = c.OwnerID
+			}
+			p.Memory[y][x] = tileMemory{Explored: true, Terrain: t.Terrain, Resource: t.Resource, Improvement: t.Improvement, Route: t.Route, CityID: t.CityID, CityOwnerID: cityOwner, OwnerID: t.OwnerID}
+		}
+	}
+}
+
+func TestFindPath(t *testing.T) {
+	oceanWall := func(g *game) {
+		for y := range g.Map {
+			if y != 12 {
+				g.Map[y][10].Terrain = terrainOcean
+			}
+		}
+		g.Map[2][10].Terrain = terrainMountains
+	}
+	cases := []struct {
+		name     string
+		setup    func(g *game, other *player)
+		from, to tilePos
+		steps    int // -1 when there is no path
+		via      *tilePos
+		avoid    *tilePos
+	}{
+		{name: "straight", from: tilePos{2, 2}, to: tilePos{5, 2}, steps: 3},
+		{name: "diagonal", from: tilePos{2, 2}, to: tilePos{5, 5}, steps: 3},
+		{name: "wraps east to west", from: tilePos{0, 5}, to: tilePos{19, 5}, steps: 1},
+		{name: "wraps north to south", from: tilePos{3, 0}, to: tilePos{3, 14}, steps: 1},
+		{name: "through the gap in the sea", setup: func(g *game, _ *player) { oceanWall(g) },
+			from: tilePos{8, 5}, to: tilePos{12, 5}, steps: 14, via: &tilePos{10, 12}},
+		{name: "around the world when the gap is held", setup: func(g *game, other *player) {
+			oceanWall(g)
+			spawnUnit(t, g, other, unitWarrior, 10, 12)
+		}, from: tilePos{8, 5}, to: tilePos{12, 5}, steps: 16, avoid: &tilePos{10, 12}},
+		{name: "around a forest", setup: func(g *game, _ *player) { g.Map[8][4].Terrain = terrainForest },
+			from: tilePos{3, 8}, to: tilePos{5, 8}, steps: 2, avoid: &tilePos{4, 8}},
+		{name: "along a road", setup: func(g *game, _ *player) {
+			for x := 2; x <= 8; x++ {
+				g.Map[6][x].Route = routeRoad
+			}
+		}, from: tilePos{2, 6}, to: tilePos{8, 6}, steps: 6},
+		{name: "detours onto a road", setup: func(g *game, _ *player) {
+			for x := 3; x <= 7; x++ {
+				g.Map[6][x].Terrain = terrainForest
+				g.Map[5][x].Route = routeRoad
+			}
+			g.Map[6][2].Route, g.Map[6][8].Route = routeRoad, routeRoad
+		}, from: tilePos{2, 6}, to: tilePos{8, 6}, steps: 6, via: &tilePos{5, 5}, avoid: &tilePos{5, 6}},
+		{name: "onto a mountain", setup: func(g *game, _ *player) { g.Map[4][4].Terrain = terrainMountains },
+			from: tilePos{3, 4}, to: tilePos{4, 4}, steps: -1},
+		{name: "into the sea", setup: func(g *game, _ *player) { g.Map[4][4].Terrain = terrainOcean },
+			from: tilePos{3, 4}, to: tilePos{4, 4}, steps: -1},
+		{name: "onto a unit", setup: func(g *game, other *player) { spawnUnit(t, g, other, unitWarrior, 6, 6) },
+			from: tilePos{3, 3}, to: tilePos{6, 6}, steps: -1},
+		{name: "to an island", setup: func(g *game, _ *player) {
+			for _, d := range [][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}} {
+				g.Map[7+d[1]][7+d[0]].Terrain = terrainOcean
+			}
+		}, from: tilePos{2, 2}, to: tilePos{7, 7}, steps: -1},
+	}
+
+	for _, tc := range cases {
+		g := newTestGame(t, aiSettings(2), 1)
+		clearBoard(g)
+		p, other := g.Players[0], g.Players[1]
+		if tc.setup != nil {
+			tc.setup(g, other)
+		}
+		revealMap(g, p)
+
+		path, err := g.findPath(p, tc.from.X, tc.from.Y, tc.to.X, tc.to.Y)
+		if tc.steps == -1 {
+			expectCode(t, tc.name, err, "NO_PATH")
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if len(path) != tc.steps || path[len(path)-1] != tc.to {
+			t.Errorf("%s: path %v, want %d steps ending at %v", tc.name, path, tc.steps, tc.to)
+		}
+		prev := tc.from
+		for _, step := range path {
+			if g.distance(prev.X, prev.Y, step.X, step.Y) != 1 || !g.isValidTile(step.X, step.Y) {
+				t.Errorf("%s: path %v steps from %v to %v", tc.name, path, prev, step)
+			}
+			if tc.avoid != nil && step == *tc.avoid {
+				t.Errorf("%s: path %v goes through %v", tc.name, path, step)
+			}
+			prev = step
+		}
+		if tc.via != nil && !slices.Contains(path, *tc.via) {
+			t.Errorf("%s: path %v doesn't go through %v", tc.name, path, *tc.via)
+		}
+	}
+}
+
+func TestGoToCarriesOnEachTurn(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	keepPlayers(g)
+	warrior := spawnUnit(t, g, p, unitWarrior, 2, 2)
+	revealMap(g, p)
+
+	result, err := g.GoTo(p.ID, warrior.ID, 2, 9)
+	if err != nil {
+		t.Fatalf("GoTo: %v", err)
+	}
+	moves := unitStats[unitWarrior].Movement
+	if g.distance(result.X, result.Y, 2, 9) != 7-moves || warrior.Destination == nil {
+		t.Fatalf("after GoTo: %+v with destination %v, want the warrior %d tiles closer and still on its way", result, warrior.Destination, moves)
+	}
+
+	for turn := 0; turn < 2*4 && warrior.Destination != nil; turn++ {
+		if _, err := g.EndTurn(g.CurrentPlayerIndex); err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+	}
+	if warrior.X != 2 || warrior.Y != 9 || warrior.Destination != nil {
+		t.Errorf("warrior at (%d,%d) heading to %v, want it arrived at (2,9)", warrior.X, warrior.Y, warrior.Destination)
+	}
+
+	g.Map[12][2].Terrain = terrainOcean
+	revealMap(g, p)
+	_, err = g.GoTo(p.ID, warrior.ID, 2, 12)
+	expectCode(t, "go to sea", err, "NO_PATH")
+}
+
+func TestUnitOrders(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	keepPlayers(g)
+	guard := spawnUnit(t, g, p, unitWarrior, 5, 5)
+	sentry := spawnUnit(t, g, p, unitWarrior, 10, 10)
+	skipper := spawnUnit(t, g, p, unitWarrior, 15, 5)
+	settler := spawnUnit(t, g, p, unitSettler, 3, 3)
+
+	idle, err := g.IdleUnits(p.ID)
+	if err != nil || len(idle) != 4 || idle[0] != guard {
+		t.Fatalf("IdleUnits = %v, %v, want all four units in ID order", idle, err)
+	}
+
+	defense := g.defenseStrength(guard)
+	for _, order := range []struct {
+		u     *unit
+		order unitOrder
+	}{{guard, orderFortify}, {sentry, orderSentry}, {skipper, orderSkip}} {
+		if err := g.SetOrder(p.ID, order.u.ID, order.order); err != nil {
+			t.Fatalf("SetOrder(%s): %v", orderToString(order.order), err)
+		}
+	}
+	if want := defense + guard.Strength*fortifyDefenseBonus; g.defenseStrength(guard) != want {
+		t.Errorf("fortified defense = %d, want %d", g.defenseStrength(guard), want)
+	}
+	if idle, _ := g.IdleUnits(p.ID); len(idle) != 1 || idle[0] != settler {
+		t.Errorf("IdleUnits = %v, want just the settler", idle)
+	}
+
+	err = g.SetOrder(p.ID, settler.ID, orderFortify)
+	expectCode(t, "fortify a settler", err, "INVALID_ORDER")
+	err = g.SetOrder(p.ID, settler.ID, orderGoTo)
+	expectCode(t, "go-to without a destination", err, "INVALID_ORDER")
+	err = g.SetOrder(p.ID, settler.ID, orderCount)
+	expectCode(t, "unknown order", err, "INVALID_ORDER")
+
+	// Next turn the skipping unit is idle again, and the sentry wakes when
+	// a foreign unit comes into sight.
+	spawnUnit(t, g, other, unitWarrior, 10+unitSightRadius, 10)
+	for range g.Players {
+		if _, err := g.EndTurn(g.CurrentPlayerIndex); err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+	}
+	if guard.Order != orderFortify || sentry.Order != orderNone || skipper.Order != orderNone {
+		t.Errorf("orders next turn: guard %s, sentry %s, skipper %s, want Fortified, None, None",
+			orderToString(guard.Order), orderToString(sentry.Order), orderToString(skipper.Order))
+	}
+}
+
+func TestExperienceAndPromotions(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	p.IsAI = false
+	g.setStance(p.ID, other.ID, stanceWar)
+
+	veteran := spawnUnit(t, g, p, unitTank, 5, 5)
+	victim := spawnUnit(t, g, other, unitWarrior, 6, 5)
+	result, err := g.MoveUnit(p.ID, veteran.ID, victim.X, victim.Y)
+	if err != nil || !result.Combat.DefenderDestroyed {
+		t.Fatalf("MoveUnit: %+v, %v, want the tank to win", result.Combat, err)
+	}
+	if veteran.Experience != experienceForAttack {
+		t.Errorf("experience after a win = %d, want %d", veteran.Experience, experienceForAttack)
+	}
+	err = g.Promote(p.ID, veteran.ID, promotionCombat)
+	expectCode(t, "promote a green unit", err, "NO_PROMOTION")
+
+	veteran.Experience = promotionThresholds[0]
+	plains := g.attackStrength(veteran, g.Map[victim.Y][victim.X].Terrain)
+	if err := g.Promote(p.ID, veteran.ID, promotionCombat); err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	if want := plains * (100 + combatPromotionBonus) / 100; g.attackStrength(veteran, g.Map[victim.Y][victim.X].Terrain) != want {
+		t.Errorf("attack with Combat = %d, want %d", g.attackStrength(veteran, g.Map[victim.Y][victim.X].Terrain), want)
+	}
+	err = g.Promote(p.ID, veteran.ID, promotionMobility)
+	expectCode(t, "promote twice on one threshold", err, "NO_PROMOTION")
+
+	veteran.Experience = promotionThresholds[1]
+	err = g.Promote(p.ID, veteran.ID, promotionCombat)
+	expectCode(t, "take Combat again", err, "PROMOTION_KNOWN")
+	if err := g.Promote(p.ID, veteran.ID, promotionMobility); err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	if want := (unitStats[unitTank].Movement + 1) * movementScale; veteran.maxMovement() != want {
+		t.Errorf("max movement with Mobility = %d, want %d", veteran.maxMovement(), want)
+	}
+
+	// The computer picks its own promotions as soon as they are earned.
+	ai := spawnUnit(t, g, other, unitWarrior, 10, 10)
+	g.Map[10][10].Terrain = terrainForest
+	ai.Experience = promotionThresholds[0] - experienceForDefense
+	g.rewardVictor(ai, experienceForDefense)
+	if len(ai.Promotions) != 1 || ai.Promotions[0] != promotionRanger {
+		t.Errorf("AI promotions in a forest = %v, want Ranger", ai.Promotions)
+	}
+}
+
+func TestBarracksTrainVeterans(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+	c.Buildings = append(c.Buildings, buildingBarracks)
+	g.assignWorkedTiles()
+	if _, err := g.addToProductionQueue(c, productionUnit, int(unitWarrior)); err != nil {
+		t.Fatalf("addToProductionQueue: %v", err)
+	}
+	c.ProductionQueue[0].Progress = c.ProductionQueue[0].TotalCost
+
+	if err := g.advanceProduction(c, owner); err != nil {
+		t.Fatalf("advanceProduction: %v", err)
+	}
+	units := owner.sortedUnits()
+	if len(units) != 1 || units[0].Experience != buildingEffects[buildingBarracks].Experience {
+		t.Errorf("units = %v, want one Warrior with %d experience", units, buildingEffects[buildingBarracks].Experience)
+	}
+}
+
+func TestHealing(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner, other := g.Players[0], g.Players[1]
+	addCity(g, owner, 5, 5)
+	addCity(g, other, 15, 5)
+	g.Map[5][6].OwnerID = owner.ID
+
+	cases := []struct {
+		name    string
+		x, y    int
+		fortify bool
+		rate    int
+	}{
+		{"in own city", 5, 5, false, healInCity},
+		{"in own territory", 6, 5, false, healInTerritory},
+		{"in the field", 10, 10, false, healInField},
+		{"fortified in the field", 10, 12, true, healInField + healFortifiedBonus},
+		{"fortified in own city", 5, 5, true, healInCity + healFortifiedBonus},
+		{"in a foreign city", 15, 5, false, healInField},
+	}
+	for _, tc := range cases {
+		g.Map[tc.y][tc.x].UnitID = -1
+		u := spawnUnit(t, g, owner, unitWarrior, tc.x, tc.y)
+		if tc.fortify {
+			u.Order = orderFortify
+		}
+		if got := g.healRate(u); got != tc.rate {
+			t.Errorf("%s: heals %d a turn, want %d", tc.name, got, tc.rate)
+		}
+		g.removeUnit(u)
+	}
+
+	resting := spawnUnit(t, g, owner, unitWarrior, 5, 5)
+	moved := spawnUnit(t, g, owner, unitWarrior, 6, 5)
+	resting.Health, moved.Health = 40, 40
+	moved.Movement--
+	g.healUnits()
+	if resting.Health != 40+healInCity || moved.Health != 40 {
+		t.Errorf("after a turn: resting unit %d HP, moved unit %d HP, want %d and 40", resting.Health, moved.Health, 40+healInCity)
+	}
+	resting.Health = maxHealth - 1
+	g.healUnits()
+	if resting.Health != maxHealth {
+		t.Errorf("healed to %d HP, want it capped at %d", resting.Health, maxHealth)
+	}
+}
+
+func TestWoundsWeakenUnits(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	a := spawnUnit(t, g, g.Players[0], unitWarrior, 5, 5)
+	d := spawnUnit(t, g, g.Players[1], unitWarrior, 6, 5)
+	attack, defense := g.attackStrength(a, g.Map[d.Y][d.X].Terrain), g.defenseStrength(d)
+
+	a.Health, d.Health = maxHealth/2, maxHealth/4
+	if got := g.attackStrength(a, g.Map[d.Y][d.X].Terrain); got != attack/2 {
+		t.Errorf("attack at half health = %d, want %d", got, attack/2)
+	}
+	if got := g.defenseStrength(d); got != defense/4 {
+		t.Errorf("defense at a quarter health = %d, want %d", got, defense/4)
+	}
+	if !d.isWounded() || a.isWounded() {
+		t.Errorf("wounded: attacker %v at %d HP, defender %v at %d HP", a.isWounded(), a.Health, d.isWounded(), d.Health)
+	}
+}
+
+func TestRouteCosts(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	g.Map[5][5].Terrain, g.Map[5][6].Terrain = terrainHills, terrainHills
+	plain := g.moveCost(5, 5, 6, 5)
+	if plain != terrainMoveCost[terrainHills]*movementScale {
+		t.Errorf("hills cost %d, want %d", plain, terrainMoveCost[terrainHills]*movementScale)
+	}
+	g.Map[5][5].Route = routeRoad
+	if got := g.moveCost(5, 5, 6, 5); got != plain {
+		t.Errorf("a road on one end only costs %d, want the terrain's %d", got, plain)
+	}
+	g.Map[5][6].Route = routeRoad
+	if got := g.moveCost(5, 5, 6, 5); got != roadMoveCost {
+		t.Errorf("road to road costs %d, want %d", got, roadMoveCost)
+	}
+	g.Map[5][5].Route, g.Map[5][6].Route = routeRailroad, routeRailroad
+	if got := g.moveCost(5, 5, 6, 5); got != railroadMoveCost {
+		t.Errorf("railroad to railroad costs %d, want %d", got, railroadMoveCost)
+	}
+	addCity(g, g.Players[0], 7, 5)
+	g.Map[5][6].Route = routeRoad
+	if got := g.moveCost(6, 5, 7, 5); got != roadMoveCost {
+		t.Errorf("road into a city costs %d, want %d: cities count as roads", got, roadMoveCost)
+	}
+}
+
+func TestWorkerImprovements(t *testing.T) {
+	cases := []struct {
+		job     workerJob
+		terrain terrainType
+		yield   tileYield
+	}{
+		{jobFarm, terrainPlains, tileYield{Food: 1}},
+		{jobMine, terrainHills, tileYield{Shields: 2}},
+		{jobRoad, terrainPlains, tileYield{Trade: 1}},
+	}
+	for _, tc := range cases {
+		g := newTestGame(t, aiSettings(2), 1)
+		clearBoard(g)
+		p := g.Players[g.CurrentPlayerIndex]
+		g.Map[5][5].Terrain = tc.terrain
+		worker := spawnUnit(t, g, p, unitWorker, 5, 5)
+		before := g.tileYield(5, 5)
+
+		if err := g.BuildImprovement(p.ID, worker.ID, tc.job); err != nil {
+			t.Fatalf("BuildImprovement(%s): %v", jobToString(tc.job), err)
+		}
+		turns := 1
+		for worker.Job != jobNone && turns < 20 {
+			worker.Movement = worker.maxMovement()
+			g.work(p, worker)
+			turns++
+		}
+		if turns != jobTurns[tc.job] {
+			t.Errorf("%s took %d turns, want %d", jobToString(tc.job), turns, jobTurns[tc.job])
+		}
+		after := g.tileYield(5, 5)
+		if got := (tileYield{after.Food - before.Food, after.Shields - before.Shields, after.Trade - before.Trade}); got != tc.yield {
+			t.Errorf("%s on %s added %+v, want %+v", jobToString(tc.job), terrainToString(tc.terrain), got, tc.yield)
+		}
+	}
+}
+
+func TestBuildImprovementErrors(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	warrior := spawnUnit(t, g, p, unitWarrior, 3, 3)
+	worker := spawnUnit(t, g, p, unitWorker, 5, 5)
+
+	err := g.BuildImprovement(p.ID, warrior.ID, jobRoad)
+	expectCode(t, "build with a warrior", err, "NOT_WORKER")
+	err = g.BuildImprovement(p.ID, worker.ID, jobMine)
+	expectCode(t, "mine the plains", err, "CANNOT_IMPROVE")
+	err = g.BuildImprovement(p.ID, worker.ID, jobRailroad)
+	expectCode(t, "railroad without a road", err, "CANNOT_IMPROVE")
+	g.Map[5][5].Route = routeRoad
+	err = g.BuildImprovement(p.ID, worker.ID, jobRailroad)
+	expectCode(t, "railroad before Industrialization", err, "ITEM_LOCKED")
+	err = g.BuildImprovement(p.ID, worker.ID, jobRoad)
+	expectCode(t, "road on a road", err, "CANNOT_IMPROVE")
+	g.Map[5][5].OwnerID = other.ID
+	err = g.BuildImprovement(p.ID, worker.ID, jobFarm)
+	expectCode(t, "farm foreign land", err, "CANNOT_IMPROVE")
+}
+
+func TestResourcesNeedTerritory(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	owner := g.Players[0]
+	c := addCity(g, owner, 5, 5)
+
+	g.Map[5][6].Resource = resourceWheat
+	plain := terrainYields[terrainPlains]
+	if got := g.tileYield(6, 5); got != plain {
+		t.Errorf("unclaimed Wheat yields %+v, want the bare %+v", got, plain)
+	}
+	g.Map[5][6].OwnerID = owner.ID
+	if got := g.tileYield(6, 5); got.Food != plain.Food+resourceYields[resourceWheat].Food {
+		t.Errorf("Wheat in our territory yields %d food, want %d", got.Food, plain.Food+resourceYields[resourceWheat].Food)
+	}
+
+	g.Map[4][5].Resource = resourceGold
+	c.WorkedTiles = []tilePos{{X: 5, Y: 4}}
+	income, luxuries := g.goldIncome(owner), g.luxuryCount(owner)
+	g.Map[4][5].OwnerID = owner.ID
+	if got := g.goldIncome(owner); got != income+goldPerMine {
+		t.Errorf("income with worked Gold = %d, want %d", got, income+goldPerMine)
+	}
+	if got := g.luxuryCount(owner); got != luxuries+1 {
+		t.Errorf("luxuries with worked Gold = %d, want %d", got, luxuries+1)
+	}
+
+	owner.Techs[techWriting], owner.Techs[techMathematics] = true, true
+	_, err := g.EnqueueProduction(owner.ID, c.ID, productionUnit, int(unitSwordsman))
+	expectCode(t, "queue a swordsman without Iron", err, "MISSING_RESOURCE")
+	g.Map[8][8].Resource = resourceIron
+	_, err = g.EnqueueProduction(owner.ID, c.ID, productionUnit, int(unitSwordsman))
+	expectCode(t, "queue a swordsman with Iron outside our lands", err, "MISSING_RESOURCE")
+	g.Map[8][8].OwnerID = owner.ID
+	if _, err := g.EnqueueProduction(owner.ID, c.ID, productionUnit, int(unitSwordsman)); err != nil {
+		t.Errorf("queue a swordsman with unworked Iron in our lands: %v", err)
+	}
+}
+
+func TestBordersFollowCulture(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	home, rival := g.Players[0], g.Players[1]
+	c := addCity(g, home, 5, 5)
+
+	for _, tc := range []struct{ culture, size int }{{0, 9}, {borderGrowth[0], 25}, {borderGrowth[1], 49}} {
+		c.Culture = tc.culture
+		g.updateTerritory()
+		if got := g.territorySize(home); got != tc.size {
+			t.Errorf("territory with %d culture = %d tiles, want %d", tc.culture, got, tc.size)
+		}
+	}
+
+	// Two cities four tiles apart, both with radius-2 borders, both
+	// reach the tiles between them.
+	c.Culture = borderGrowth[0]
+	other := addCity(g, rival, 9, 5)
+	other.Culture = borderGrowth[0]
+	g.updateTerritory()
+	held := g.Map[5][7].OwnerID
+	if held == -1 {
+		t.Fatal("the tile between the cities is unclaimed")
+	}
+	g.updateTerritory()
+	if g.Map[5][7].OwnerID != held {
+		t.Error("an evenly contested tile changed hands")
+	}
+	if g.Map[5][6].OwnerID != home.ID || g.Map[5][8].OwnerID != rival.ID {
+		t.Error("each city should hold the contested tiles nearest it")
+	}
+	other.Culture = borderGrowth[1]
+	g.updateTerritory()
+	if g.Map[5][7].OwnerID != rival.ID || g.Map[5][6].OwnerID != rival.ID {
+		t.Errorf("the more cultured rival holds (7,5) and (6,5) as %d and %d, want both %d",
+			g.Map[5][7].OwnerID, g.Map[5][6].OwnerID, rival.ID)
+	}
+	if g.Map[5][5].OwnerID != home.ID {
+		t.Error("a city lost its own tile")
+	}
+
+	g.CurrentPlayerIndex = home.ID
+	settler := spawnUnit(t, g, home, unitSettler, 7, 5)
+	_, err := g.FoundCity(home.ID, settler.ID, "Border Town")
+	expectCode(t, "found inside a rival's borders", err, "FOREIGN_TERRITORY")
+}
+
+func TestCaptureAndRaze(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	current := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+	home := addCity(g, current, 2, 2)
+	capital := addCity(g, other, 10, 10)
+	outpost := addCity(g, other, 14, 10)
+	outpost.Population = 3
+	worker := spawnUnit(t, g, current, unitWorker, 13, 10)
+	warrior := spawnUnit(t, g, current, unitWarrior, 15, 10)
+
+	g.setStance(current.ID, other.ID, stancePeace)
+	_, err := g.MoveUnit(current.ID, warrior.ID, 14, 10)
+	expectCode(t, "take a city in peacetime", err, "NOT_AT_WAR")
+	g.setStance(current.ID, other.ID, stanceWar)
+	_, err = g.MoveUnit(current.ID, worker.ID, 14, 10)
+	expectCode(t, "take a city with a worker", err, "CANNOT_CAPTURE")
+	err = g.RazeCity(current.ID, home.ID)
+	expectCode(t, "raze a city held since the start", err, "CANNOT_RAZE")
+
+	result, err := g.MoveUnit(current.ID, warrior.ID, 14, 10)
+	if err != nil {
+		t.Fatalf("MoveUnit into the undefended city: %v", err)
+	}
+	if result.CapturedCity != outpost || outpost.OwnerID != current.ID || current.Cities[outpost.ID] != outpost {
+		t.Fatalf("the outpost is held by %d, want it captured by %d", outpost.OwnerID, current.ID)
+	}
+	if outpost.Population != 2 || !outpost.Captured || warrior.Movement != 0 {
+		t.Errorf("after capture: pop %d, captured %v, %d movement left; want 2, true, 0", outpost.Population, outpost.Captured, warrior.Movement)
+	}
+	if other.Eliminated {
+		t.Error("a civilization with a city left was eliminated")
+	}
+
+	if err := g.RazeCity(current.ID, outpost.ID); err != nil {
+		t.Fatalf("RazeCity: %v", err)
+	}
+	if _, exists := current.Cities[outpost.ID]; exists || g.Map[10][14].CityID != -1 {
+		t.Error("the razed city is still on the map")
+	}
+
+	// Taking the last city knocks the other civilization out
+	g.removeUnit(warrior)
+	warrior = spawnUnit(t, g, current, unitWarrior, 11, 10)
+	if _, err := g.MoveUnit(current.ID, warrior.ID, 10, 10); err != nil {
+		t.Fatalf("MoveUnit into the capital: %v", err)
+	}
+	if !other.Eliminated || capital.OwnerID != current.ID {
+		t.Fatal("losing the last city didn't eliminate the civilization")
+	}
+	_, err = g.ProposeTreaty(current.ID, other.ID, proposePeace)
+	expectCode(t, "make peace with the eliminated", err, "PLAYER_ELIMINATED")
+
+	if _, err := g.EndTurn(current.ID); err != nil {
+		t.Fatalf("EndTurn: %v", err)
+	}
+	if capital.Captured {
+		t.Error("the capital can still be razed after the turn ended")
+	}
+	if g.CurrentPlayerIndex != current.ID {
+		t.Errorf("turn passed to player %d, want the eliminated civilization skipped", g.CurrentPlayerIndex)
+	}
+}
+
+func TestCultureVictory(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p := g.Players[0]
+	capital := p.sortedCities()[0]
+
+	capital.Culture = g.Settings.cultureVictoryPoints() - 1
+	if _, won := g.cultureVictor(); won {
+		t.Errorf("culture victory with %d of %d culture", capital.Culture, g.Settings.cultureVictoryPoints())
+	}
+	capital.Culture++
+	if winner, won := g.cultureVictor(); !won || winner != p.ID {
+		t.Errorf("cultureVictor = %d, %v with %d culture, want %s to win", winner, won, capital.Culture, p.Name)
+	}
+	g.Settings.Victories[victoryCulture] = false
+	if err := g.checkGameOver(); err != nil {
+		t.Errorf("checkGameOver with culture victory off: %v", err)
+	}
+}
+
+func TestScienceVictory(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p := g.Players[g.CurrentPlayerIndex]
+	capital := p.sortedCities()[0]
+
+	_, err := g.EnqueueProduction(p.ID, capital.ID, productionProject, int(projectApolloProgram))
+	expectCode(t, "start the Apollo Program without Industrialization", err, "ITEM_LOCKED")
+	p.Techs[techIndustrialization] = true
+	_, err = g.EnqueueProduction(p.ID, capital.ID, productionProject, int(projectSpaceshipHull))
+	expectCode(t, "build the hull before the Apollo Program", err, "ITEM_LOCKED")
+
+	for project := projectApolloProgram; project < projectCount; project++ {
+		if next, ok := p.nextProject(); !ok || next != project {
+			t.Fatalf("nextProject = %s, %v, want %s", projectToString(next), ok, projectToString(project))
+		}
+		capital.ProductionQueue = nil
+		if _, err := g.EnqueueProduction(p.ID, capital.ID, productionProject, int(project)); err != nil {
+			t.Fatalf("queue the %s: %v", projectToString(project), err)
+		}
+		capital.ProductionQueue[0].Progress = capital.ProductionQueue[0].TotalCost
+		if err := g.advanceProduction(capital, p); err != nil {
+			t.Fatalf("advanceProduction: %v", err)
+		}
+		if !p.Projects[project] {
+			t.Fatalf("the %s wasn't completed", projectToString(project))
+		}
+		if err := g.checkGameOver(); (err != nil) != (project == projectCount-1) {
+			t.Errorf("checkGameOver after the %s: %v", projectToString(project), err)
+		}
+	}
+	if g.WinnerID != p.ID || g.Victory != victoryScience {
+		t.Errorf("winner %d by %s victory, want %d by Science", g.WinnerID, victoryToString(g.Victory), p.ID)
+	}
+}
+
+func TestAIVoteChoice(t *testing.T) {
+	g := newTestGame(t, aiSettings(3), 3)
+	a, b := g.Players[0], g.Players[1]
+	if got := g.aiVoteChoice(b); got != b.ID {
+		t.Errorf("with no friends %s votes for %d, want itself", b.Name, got)
+	}
+
+	g.setStance(b.ID, a.ID, stancePeace)
+	b.Relations[a.ID] = voteRelation
+	if got := g.aiVoteChoice(b); got != a.ID {
+		t.Errorf("%s votes for %d, want its friend %s", b.Name, got, a.Name)
+	}
+	b.Relations[a.ID] = voteRelation - 1
+	if got := g.aiVoteChoice(b); got != b.ID {
+		t.Errorf("%s votes for %d on lukewarm relations, want itself", b.Name, got)
+	}
+	b.Relations[a.ID] = voteRelation
+	a.Eliminated = true
+	if got := g.aiVoteChoice(b); got != b.ID {
+		t.Errorf("%s votes for the eliminated %s", b.Name, a.Name)
+	}
+}
+
+func TestAccessorsOnlyShowOwnState(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	current := g.Players[g.CurrentPlayerIndex]
+	other := g.Players[1-g.CurrentPlayerIndex]
+
+	if p, err := g.Player(current.ID); err != nil || p != current {
+		t.Errorf("Player(own) = %v, %v", p, err)
+	}
+	_, err := g.Player(other.ID)
+	expectCode(t, "rival's player state", err, "NOT_YOUR_TURN")
+
+	_, err = g.IdleUnits(other.ID)
+	expectCode(t, "rival's idle units", err, "NOT_YOUR_TURN")
+	_, err = g.ResearchOptions(other.ID)
+	expectCode(t, "rival's research options", err, "NOT_YOUR_TURN")
+	if techs, err := g.ResearchOptions(current.ID); err != nil || len(techs) == 0 {
+		t.Errorf("ResearchOptions(own) = %v, %v", techs, err)
+	}
+
+	own := current.sortedUnits()[0]
+	if u, err := g.Unit(current.ID, own.ID); err != nil || u != own {
+		t.Errorf("Unit(own) = %v, %v", u, err)
+	}
+	_, err = g.Unit(current.ID, other.sortedUnits()[0].ID)
+	expectCode(t, "rival's unit", err, "UNIT_NOT_FOUND")
+	_, err = g.City(current.ID, other.sortedCities()[0].ID)
+	expectCode(t, "rival's city", err, "CITY_NOT_FOUND")
+
+	rival := other.sortedCities()[0]
+	view, err := g.VisibleTile(current.ID, rival.X, rival.Y)
+	if err != nil {
+		t.Fatalf("VisibleTile: %v", err)
+	}
+	if view.Explored || view.CityID != -1 {
+		t.Errorf("the rival capital at (%d,%d) is on the map before anyone saw it: %+v", rival.X, rival.Y, view)
+	}
+}
+
+func TestCultureVictoryComesLate(t *testing.T) {
+	settings := aiSettings(4)
+	settings.Victories[victoryScience] = false
+	settings.Victories[victoryDiplomatic] = false
+	for _, seed := range []uint64{1, 2, 9} {
+		g := newTestGame(t, settings, seed)
+		playTurns(t, g, 1<<30)
+		if g.Victory == victoryCulture && g.Year < 1500 {
+			t.Errorf("seed %d: culture victory in %s, want it no earlier than 1500 AD", seed, formatYear(g.Year))
+		}
+	}
+}
+
+func TestTimeVictorSkipsEliminated(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	g.Year = g.Settings.EndYear
+	leader, winner := g.Players[0], g.Players[1]
+	leader.sortedCities()[0].Culture = 100000
+	leader.Eliminated = true
+
+	if id, won := g.timeVictor(); !won || id != winner.ID {
+		t.Errorf("timeVictor = %d, %v, want %s, the only civilization left", id, won, winner.Name)
+	}
+}
+
+// voteRound has every civilization in turn vote for the candidate given
+// for it, or abstain if there is none, and returns the last EndTurn's
+// result.
+func voteRound(t *testing.T, g *game, candidates map[int]int) turnResult {
+	t.Helper()
+	var result turnResult
+	for range g.Players {
+		p := g.Players[g.CurrentPlayerIndex]
+		if candidate, ok := candidates[p.ID]; ok {
+			if err := g.CastVote(p.ID, candidate); err != nil {
+				t.Fatalf("CastVote(%d, %d): %v", p.ID, candidate, err)
+			}
+		}
+		var err error
+		if result, err = g.EndTurn(p.ID); err != nil {
+			t.Fatalf("EndTurn: %v", err)
+		}
+	}
+	return result
+}
+
+func TestWorldVote(t *testing.T) {
+	g := newTestGame(t, aiSettings(4), 3)
+	err := g.CastVote(0, 0)
+	expectCode(t, "vote between votes", err, "NO_VOTE")
+
+	g.TurnCount = voteInterval
+	result := voteRound(t, g, map[int]int{0: 0, 1: 1, 2: 0})
+	if result.GameOver {
+		t.Fatalf("two of four votes won the game: %+v", result)
+	}
+	if g.VoteTurn != voteInterval || g.VoteTally[0] != 2 || g.VoteTally[1] != 1 {
+		t.Errorf("vote counted on turn %d as %v, want turn %d with 2, 1, 0, 0", g.VoteTurn, g.VoteTally, voteInterval)
+	}
+	if len(g.Ballots) != 0 {
+		t.Errorf("ballots %v left over after the count", g.Ballots)
+	}
+	err = g.CastVote(g.CurrentPlayerIndex, 0)
+	expectCode(t, "vote the turn after a vote", err, "NO_VOTE")
+
+	g.TurnCount = 2 * voteInterval
+	result = voteRound(t, g, map[int]int{0: 2, 1: 2, 2: 2})
+	if !result.GameOver || result.Victory != victoryDiplomatic || result.WinnerID != 2 {
+		t.Errorf("three of four votes for player 2 gave %+v, want their diplomatic victory", result)
+	}
+}
+
+func TestProjectsNeedScienceVictory(t *testing.T) {
+	settings := aiSettings(2)
+	settings.Victories[victoryScience] = false
+	g := newTestGame(t, settings, 3)
+	p := g.Players[g.CurrentPlayerIndex]
+	p.Techs[techIndustrialization] = true
+
+	_, err := g.EnqueueProduction(p.ID, p.sortedCities()[0].ID, productionProject, int(projectApolloProgram))
+	expectCode(t, "space program without science victory", err, "VICTORY_DISABLED")
+}
+
+func TestRiotsRepeat(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p, rival := g.Players[0], g.Players[1]
+	capital := p.sortedCities()[0]
+	capital.Population = 12
+	// No civilization is happier, so the city has nowhere to defect to
+	rival.Happiness = -1
+
+	for turn := 1; turn <= 3*riotTurns; turn++ {
+		before := capital.Population
+		g.updateHappiness(p)
+		rioted := capital.Population < before
+		if want := turn%riotTurns == 0; rioted != want {
+			t.Errorf("turn %d of disorder: rioted = %v, want %v", turn, rioted, want)
+		}
+	}
+	if capital.OwnerID != p.ID {
+		t.Fatalf("%s defected with nowhere to go", capital.Name)
+	}
+}
+
+// scriptedInput feeds lines to an inputValidator as if they were typed.
+func scriptedInput(lines ...string) *inputValidator {
+	return newInputValidator(bufio.NewScanner(strings.NewReader(strings.Join(lines, "\n") + "\n")))
+}
+
+func TestSettingsMenu(t *testing.T) {
+	// random map, skirmish size, 3 players of which 2 AI, 25 years a
+	// turn from 3000 BC to 500 BC, and every victory but science
+	input := scriptedInput("2", "1", "3", "2", "25", "-3000", "-500", "1", "1", "0", "1", "1")
+	settings, err := settingsMenu(defaultSettings(), input)
+	if err != nil {
+		t.Fatalf("settingsMenu: %v", err)
+	}
+	want := gameSettings{
+		MapWidth: 10, MapHeight: 10, MapGenerator: mapGenRandom,
+		Players: 3, AIPlayers: 2, StartYear: -3000, EndYear: -500, YearsPerTurn: 25,
+		Victories: [victoryCount]bool{true, true, false, true, true},
+	}
+	if settings != want {
+		t.Errorf("settingsMenu = %+v, want %+v", settings, want)
+	}
+
+	_, err = settingsMenu(defaultSettings(), scriptedInput("1", "1", "2", "0", "10", "-3000", "-3000"))
+	expectCode(t, "end year before the start", err, "OUT_OF_BOUNDS")
+}
+
+func TestLoadMigratesVersion1(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	warrior := g.Players[0].sortedUnits()[1]
+
+	// A version 1 game had no settings, counted years up from 4000 BC and
+	// whole movement points.
+	old := *g
+	old.Settings = gameSettings{}
+	old.Year = 4000 + 30
+	warrior.Movement /= movementScale
+	data, err := json.Marshal(saveFile{Version: 1, Game: &old})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "v1.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	loaded, err := loadGame(path)
+	if err != nil {
+		t.Fatalf("loadGame: %v", err)
+	}
+	if loaded.Year != -3970 {
+		t.Errorf("year %s, want 3970 BC", formatYear(loaded.Year))
+	}
+	if loaded.Settings.MapWidth != 20 || loaded.Settings.Players != 2 || loaded.Settings.AIPlayers != 2 {
+		t.Errorf("settings %+v, want the 20x15 defaults for 2 AI players", loaded.Settings)
+	}
+	if moves := loaded.Players[0].Units[warrior.ID].Movement; moves != warrior.maxMovement() {
+		t.Errorf("warrior has %d movement points, want %d", moves, warrior.maxMovement())
+	}
+}
+
+func TestBankruptcyCarriesDebt(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p := g.Players[0]
+	p.Gold = -500
+	want := p.Gold + g.goldIncome(p) - g.goldUpkeep(p)
+
+	g.collectGold(p)
+	if p.Gold != want || !p.Bankrupt {
+		t.Errorf("gold %d, bankrupt %v after paying the turn's books, want the debt carried as %d", p.Gold, p.Bankrupt, want)
+	}
+}
+
+func TestBankruptcyCanEliminate(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p := g.Players[0]
+	g.destroyCity(p.sortedCities()[0])
+	if p.Eliminated {
+		t.Fatalf("%s eliminated while it still has a settler", p.Name)
+	}
+	p.Gold = -10
+
+	g.collectGold(p)
+	if p.UnitCount != 0 || !p.Eliminated {
+		t.Errorf("%s has %d units and eliminated = %v, want its settler disbanded and the civilization gone", p.Name, p.UnitCount, p.Eliminated)
+	}
+}
+
+func TestFortify(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	p, warrior := startingUnit(t, g, unitWarrior)
+	_, settler := startingUnit(t, g, unitSettler)
+
+	err := g.SetOrder(p.ID, settler.ID, orderFortify)
+	expectCode(t, "fortify a settler", err, "INVALID_ORDER")
+	if err := g.SetOrder(p.ID, warrior.ID, orderFortify); err != nil {
+		t.Fatalf("SetOrder: %v", err)
+	}
+	err = g.SetOrder(p.ID, warrior.ID, orderFortify)
+	expectCode(t, "fortify twice", err, "INVALID_ORDER")
+
+	warrior.setOrder(orderNone)
+	warrior.Movement = 0
+	err = g.SetOrder(p.ID, warrior.ID, orderFortify)
+	expectCode(t, "fortify without moves left", err, "NO_MOVES_LEFT")
+}
+
+func TestEndTurnWithEveryoneEliminated(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 3)
+	current := g.Players[g.CurrentPlayerIndex]
+	for _, p := range g.Players {
+		p.Eliminated = true
+	}
+
+	result, err := g.EndTurn(current.ID)
+	if err != nil {
+		t.Fatalf("EndTurn: %v", err)
+	}
+	if !result.GameOver || result.WinnerID != -1 || g.Running {
+		t.Errorf("EndTurn = %+v, running = %v, want the game over without a winner", result, g.Running)
+	}
+}
+
+func TestSortEvents(t *testing.T) {
+	settings := aiSettings(3)
+	settings.AIPlayers = 1
+	g := newTestGame(t, settings, 1)
+	human, other, ai := g.Players[0], g.Players[1], g.Players[2]
+	events := []gameEvent{
+		{PlayerID: -1, Message: "news"},
+		{PlayerID: human.ID, Message: "mine"},
+		{PlayerID: other.ID, Message: "the other human's"},
+		{PlayerID: ai.ID, Message: "the AI's"},
+	}
+
+	inbox := make(map[int][]gameEvent)
+	shown := g.sortEvents(events, human.ID, inbox)
+	if len(shown) != 2 || shown[0].Message != "news" || shown[1].Message != "mine" {
+		t.Errorf("shown %v, want only the news and the viewer's own event", shown)
+	}
+	if len(inbox) != 1 || len(inbox[other.ID]) != 1 || inbox[other.ID][0].Message != "the other human's" {
+		t.Errorf("inbox %v, want only the other human's event held", inbox)
+	}
+
+	clear(inbox)
+	shown = g.sortEvents(events, -1, inbox)
+	if len(shown) != 1 || len(inbox) != 2 {
+		t.Errorf("with an AI at the keyboard: shown %v, inbox %v; want the news shown and both humans' events held", shown, inbox)
+	}
+}
+
+func TestRunStopsAtEndOfInput(t *testing.T) {
+	settings := aiSettings(2)
+	settings.AIPlayers = 1
+	g := newTestGame(t, settings, 1)
+
+	done := make(chan struct{})
+	go func() {
+		// View the map, then type something bad, then nothing more
+		g.Run(scriptedInput("1", "x"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run kept asking for input after it ran out")
+	}
+	if g.Year != settings.StartYear {
+		t.Errorf("the game went on to %s after the input ran out", formatYear(g.Year))
+	}
+}
+
+func TestAttackClearsOrders(t *testing.T) {
+	for _, order := range []unitOrder{orderFortify, orderSentry, orderGoTo} {
+		g := newTestGame(t, aiSettings(2), 5)
+		clearBoard(g)
+		current := g.Players[g.CurrentPlayerIndex]
+		other := g.Players[1-g.CurrentPlayerIndex]
+		attacker := spawnUnit(t, g, current, unitTank, 5, 5)
+		spawnUnit(t, g, other, unitWarrior, 6, 5)
+		g.setStance(current.ID, other.ID, stanceWar)
+		attacker.Order = order
+		if order == orderGoTo {
+			attacker.Destination = &tilePos{X: 9, Y: 5}
+		}
+
+		if _, err := g.MoveUnit(current.ID, attacker.ID, 6, 5); err != nil {
+			t.Fatalf("%s: MoveUnit: %v", orderToString(order), err)
+		}
+		if attacker.Order != orderNone || attacker.Destination != nil {
+			t.Errorf("after attacking, the %s tank has order %s heading to %v, want no orders",
+				orderToString(order), orderToString(attacker.Order), attacker.Destination)
+		}
+	}
+}
+
+func TestAIGarrisonsCities(t *testing.T) {
+	g := newTestGame(t, aiSettings(2), 1)
+	clearBoard(g)
+	p := g.Players[g.CurrentPlayerIndex]
+	held := addCity(g, p, 5, 5)
+	empty := addCity(g, p, 12, 5)
+	guard := spawnUnit(t, g, p, unitWarrior, held.X, held.Y)
+	near := spawnUnit(t, g, p, unitWarrior, 15, 5)
+	far := spawnUnit(t, g, p, unitWarrior, 17, 12)
+	revealMap(g, p)
+
+	if err := g.aiTurn(p); err != nil {
+		t.Fatalf("aiTurn: %v", err)
+	}
+	if guard.X != held.X || guard.Y != held.Y || guard.Order != orderFortify {
+		t.Errorf("the guard is at (%d,%d) with order %s, want it fortified in %s", guard.X, guard.Y, orderToString(guard.Order), held.Name)
+	}
+	if near.Destination == nil || *near.Destination != (tilePos{X: empty.X, Y: empty.Y}) {
+		t.Errorf("the nearest warrior is heading to %v, want it sent to the empty city at (%d,%d)", near.Destination, empty.X, empty.Y)
+	}
+	if far.Destination != nil && *far.Destination == (tilePos{X: empty.X, Y: empty.Y}) {
+		t.Error("a second warrior was sent to the same city")
+	}
+
+	for turn := 0; turn < 5 && g.Map[empty.Y][empty.X].UnitID != near.ID; turn++ {
+		near.Movement = near.maxMovement()
+		if err := g.aiTurn(p); err != nil {
+			t.Fatalf("aiTurn: %v", err)
+		}
+	}
+	near.Movement = near.maxMovement()
+	if err := g.aiTurn(p); err != nil {
+		t.Fatalf("aiTurn: %v", err)
+	}
+	if near.X != empty.X || near.Y != empty.Y || near.Order != orderFortify {
+		t.Errorf("the warrior is at (%d,%d) with order %s, want it fortified in %s", near.X, near.Y, orderToString(near.Order), empty.Name)
+	}
+}
+
+func TestTechCostsFollowGameLength(t *testing.T) {
+	standard := defaultSettings()
+	half := standard
+	half.YearsPerTurn *= 2
+	short := standard
+	short.StartYear, short.EndYear, short.YearsPerTurn = -1000, -900, 25
+
+	for tech := techAgriculture; tech < techCount; tech++ {
+		base := techTree[tech].Cost
+		if got := standard.techCost(tech); got != base {
+			t.Errorf("%s costs %d in a standard game, want %d", techToString(tech), got, base)
+		}
+		if got, want := half.techCost(tech), base*half.turns()/standard.turns(); got != want {
+			t.Errorf("%s costs %d in a game of half the turns, want %d", techToString(tech), got, want)
+		}
+		if got := short.techCost(tech); base > 0 && got != 1 {
+			t.Errorf("%s costs %d in a four-turn game, want 1", techToString(tech), got)
+		}
+	}
+
+	// A short game gets a technology a turn
+	settings := aiSettings(2)
+	settings.StartYear, settings.EndYear, settings.YearsPerTurn = short.StartYear, short.EndYear, short.YearsPerTurn
+	g := newTestGame(t, settings, 1)
+	p := g.Players[g.CurrentPlayerIndex]
+	if err := g.SetResearch(p.ID, techPottery); err != nil {
+		t.Fatalf("SetResearch: %v", err)
+	}
+	g.advanceResearch(p)
+	if !p.Techs[techPottery] {
+		t.Errorf("Pottery at %d of %d points isn't known after a turn of a four-turn game", p.ResearchProgress, short.techCost(techPottery))
+	}
+}